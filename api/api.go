@@ -0,0 +1,408 @@
+// Package api implements node http api for clients.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/events"
+	"github.com/lavrs/blkchn/mempool"
+	"github.com/lavrs/blkchn/metrics"
+	"github.com/lavrs/blkchn/miner"
+	"github.com/lavrs/blkchn/p2p"
+	"github.com/lavrs/blkchn/schema"
+	"github.com/lavrs/blkchn/snapshot"
+	"github.com/lavrs/blkchn/webhook"
+)
+
+// Node type for access node from handlers
+type Node interface {
+	// Blocks returns all blockchain blocks
+	Blocks() []*chain.Block
+	// Block returns block by index
+	Block(index int) (*chain.Block, bool)
+	// BlockByHash returns block by its hash
+	BlockByHash(hash string) (*chain.Block, bool)
+	// BlocksRange returns at most limit blocks starting from index
+	BlocksRange(from, limit int) []*chain.Block
+	// HeadersRange returns at most limit block headers starting from index
+	HeadersRange(from, limit int) []*chain.Header
+	// Height returns latest block index
+	Height() int
+	// FactStatus returns fact by id and where it is
+	FactStatus(id string) (*chain.Fact, *chain.FactStatus, bool)
+	// QueryFacts returns confirmed facts matching query
+	QueryFacts(q chain.Query) []*chain.FoundFact
+	// PendingFacts returns unconfirmed facts
+	PendingFacts() []*chain.Fact
+	// MempoolStats returns unconfirmed facts pool usage and limits
+	MempoolStats() mempool.Stats
+	// MiningJob returns current mining block and its version
+	MiningJob() (*chain.Block, uint64)
+	// SubmitFact adds new unconfirmed fact of type and author and notifies
+	// nodes, returns error if node doesn't accept facts, fact doesn't match
+	// schema of its type or doesn't fit in pool
+	SubmitFact(typ, author string, fact interface{}) (*chain.Fact, error)
+	// SubmitBlob adds new unconfirmed fact with data kept off-chain,
+	// returns error like SubmitFact or if data is too large
+	SubmitBlob(typ, author string, data []byte) (*chain.Fact, error)
+	// Blob returns fact data kept off-chain by its hash,
+	// missing blob is requested from nodes
+	Blob(ctx context.Context, hash string) ([]byte, error)
+	// Schemas returns registered fact types
+	Schemas() []*schema.Entry
+	// Schema returns registered fact type
+	Schema(typ string) (*schema.Entry, bool)
+	// RegisterSchema registers fact type with schema,
+	// returns false if the same schema is already registered
+	RegisterSchema(typ string, data []byte) (*schema.Entry, bool, error)
+	// MiningEnabled reports whether node accepts mining solutions
+	MiningEnabled() bool
+	// Mine starts trying to solve mining block of job version
+	// with nonce, returns error if node doesn't accept solutions
+	Mine(nonce string, job uint64) error
+	// Addr returns node websocket address for other nodes
+	Addr() string
+	// Peers returns connected nodes
+	Peers() []*p2p.Peer
+	// Peer returns connected node by id
+	Peer(id uint64) *p2p.Peer
+	// Connect connects to node
+	Connect(addr string) (*p2p.Peer, error)
+	// Subscribe returns subscription to node events matching filter
+	Subscribe(f events.Filter) *events.Subscription
+	// Webhooks returns webhooks of confirmed facts
+	Webhooks() *webhook.Dispatcher
+	// Snapshot returns the latest node state snapshot, nil if there is no one
+	Snapshot() *snapshot.Snapshot
+	// WriteMetrics writes node metrics in prometheus text exposition format
+	WriteMetrics(w io.Writer) error
+}
+
+// Response type for communicate with clients
+type Response struct {
+	// error message
+	Error string `json:"error,omitempty"`
+	// id of the created fact
+	Id       string          `json:"id,omitempty"`
+	VMBlocks *chain.VMBlocks `json:"vm_blocks,omitempty"`
+	// mining block version
+	Job uint64 `json:"job,omitempty"`
+	// connected nodes info
+	Peers []p2p.PeerInfo `json:"peers,omitempty"`
+	// single node info
+	Peer *p2p.PeerInfo `json:"peer,omitempty"`
+	// node address to connect
+	Addr       string         `json:"addr,omitempty"`
+	Facts      []*chain.Fact  `json:"facts,omitempty"`
+	Blockchain []*chain.Block `json:"blockchain,omitempty"`
+}
+
+// API type for serve node http api
+type API struct {
+	node Node
+	log  *log.Logger
+}
+
+// New returns api of node
+func New(n Node, logger *log.Logger) *API {
+	return &API{node: n, log: logger}
+}
+
+// Register registers api handlers on mux
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/blockchain", a.blockchainHandler)
+	mux.HandleFunc("/fact", a.factHandler)
+	mux.HandleFunc("/mine", a.mineHandler)
+	mux.HandleFunc("/peers", a.peersHandler)
+	mux.HandleFunc("/peers/", a.peerHandler)
+	mux.HandleFunc("/facts", a.queryFactsHandler)
+	mux.HandleFunc("/facts/", a.factStatusHandler("/facts/"))
+	mux.HandleFunc("/mempool", a.mempoolHandler)
+	mux.HandleFunc("/snapshot/latest", a.snapshotHandler)
+	mux.HandleFunc("/blobs/", a.blobHandler)
+	mux.HandleFunc("/metrics", a.metricsHandler)
+
+	mux.HandleFunc("/v1/blocks", a.v1BlocksHandler)
+	mux.HandleFunc("/v1/blocks/", a.v1BlockHandler)
+	mux.HandleFunc("/v1/facts", a.v1FactsHandler)
+	mux.HandleFunc("/v1/facts/", a.factStatusHandler("/v1/facts/"))
+	mux.HandleFunc("/v1/mempool", a.mempoolHandler)
+	mux.HandleFunc("/v1/mining", a.v1MiningHandler)
+	mux.HandleFunc("/v1/schemas", a.v1SchemasHandler)
+	mux.HandleFunc("/v1/schemas/", a.v1SchemaHandler)
+	mux.HandleFunc("/v1/events", a.v1EventsHandler)
+	mux.HandleFunc("/v1/events/ws", a.v1EventsWSHandler)
+	mux.HandleFunc("/v1/webhooks", a.v1WebhooksHandler)
+	mux.HandleFunc("/v1/webhooks/", a.v1WebhookHandler)
+}
+
+// handle block request
+// sending blockchain and mining block
+func (a *API) blockchainHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/blockchain")
+
+	blocks := a.node.Blocks()
+	mining, job := a.node.MiningJob()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(Response{
+		Blockchain: blocks,
+		VMBlocks: &chain.VMBlocks{
+			MiningBlock: mining,
+		},
+		Job: job,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// handler, that when requested by method get,
+// sends the facts of the specified block,
+// and, if requested by method post, takes a new unconfirmed fact
+func (a *API) factHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/fact", r.Method)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		blk, ok := a.node.Block(id)
+		if err == nil && a.pruned(id) {
+			writeError(w, http.StatusGone, "Block facts are pruned")
+			return
+		}
+		// send that received id is invalid
+		if err != nil || !ok {
+			writeError(w, http.StatusBadRequest, "Invalid block id")
+			return
+		}
+
+		// send block facts
+		err = json.NewEncoder(w).Encode(Response{
+			Facts: blk.Facts,
+		})
+		if err != nil {
+			panic(err)
+		}
+	case http.MethodPost:
+		var fact interface{}
+		err := json.NewDecoder(r.Body).Decode(&fact)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid incoming data")
+			return
+		}
+
+		t, err := a.node.SubmitFact(r.URL.Query().Get("type"), r.URL.Query().Get("author"), fact)
+		if err != nil {
+			code, _ := submitError(err)
+			writeError(w, code, err.Error())
+			return
+		}
+
+		// send fact id
+		err = json.NewEncoder(w).Encode(Response{Id: t.Id})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// handle that try mining
+func (a *API) mineHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/mine")
+
+	if !a.node.MiningEnabled() {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusForbidden, "Mining is disabled")
+		return
+	}
+
+	// solution for any mining block version if job is absent
+	var job uint64
+	if s := r.URL.Query().Get("job"); s != "" {
+		var err error
+		job, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			writeError(w, http.StatusBadRequest, "Invalid job")
+			return
+		}
+	}
+
+	// try mining
+	err := a.node.Mine(r.URL.Query().Get("nonce"), job)
+	switch {
+	case err == miner.ErrStaleJob:
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	}
+}
+
+// handler, that when requested by method get,
+// sends connected nodes info,
+// and, if requested by method post, connects to a new node
+func (a *API) peersHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/peers", r.Method)
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		current := a.node.Peers()
+		t := Response{Addr: a.node.Addr(), Peers: make([]p2p.PeerInfo, len(current))}
+		for i, p := range current {
+			t.Peers[i] = p.Info()
+		}
+
+		err := json.NewEncoder(w).Encode(t)
+		if err != nil {
+			panic(err)
+		}
+	case http.MethodPost:
+		var t Response
+		err := json.NewDecoder(r.Body).Decode(&t)
+		if err != nil || t.Addr == "" {
+			writeError(w, http.StatusBadRequest, "Invalid node address")
+			return
+		}
+
+		p, err := a.node.Connect(t.Addr)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "Failed to connect to node")
+			return
+		}
+
+		pInfo := p.Info()
+		w.WriteHeader(http.StatusCreated)
+		err = json.NewEncoder(w).Encode(Response{Peer: &pInfo})
+		if err != nil {
+			panic(err)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handler, that when requested by method delete,
+// disconnects specified node
+func (a *API) peerHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/peers/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid node id")
+		return
+	}
+
+	p := a.node.Peer(id)
+	if p == nil {
+		writeError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+
+	p.Close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handler, that sends the latest node state snapshot,
+// joining nodes start from it instead of genesis block
+func (a *API) snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/snapshot/latest", r.Method)
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	s := a.node.Snapshot()
+	if s == nil {
+		writeError(w, http.StatusNotFound, "No snapshot yet")
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// handler, that sends fact data kept off-chain by its hash,
+// data is checked against hash before it is sent
+func (a *API) blobHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	hash := strings.TrimPrefix(r.URL.Path, "/blobs/")
+	if !blob.IsHash(hash) {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusBadRequest, "Invalid blob hash")
+		return
+	}
+
+	data, err := a.node.Blob(r.Context(), hash)
+	if err == blob.ErrNotFound {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusNotFound, "Blob not found")
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	// blob never changes
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Write(data)
+}
+
+// send error message with status code
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(Response{Error: msg})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// handle metrics request of monitoring system
+func (a *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, "/metrics", r.Method)
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	err := a.node.WriteMetrics(w)
+	if err != nil {
+		a.log.Println("Failed to write metrics:", err)
+	}
+}
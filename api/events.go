@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lavrs/blkchn/events"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// period of sending comments to keep event stream open
+	keepAlivePeriod = 15 * time.Second
+	// time allowed to write an event to the client
+	eventWriteWait = 10 * time.Second
+	// type of the last event sent when client
+	// didn't keep up with events
+	lagged = "lagged"
+)
+
+// returns events filter from query, types and fact types are
+// separated by comma or given in several parameters
+func eventsFilter(r *http.Request) (events.Filter, error) {
+	var (
+		f    events.Filter
+		list = func(name string) []string {
+			var items []string
+			for _, v := range r.URL.Query()[name] {
+				for _, item := range strings.Split(v, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						items = append(items, item)
+					}
+				}
+			}
+			return items
+		}
+	)
+
+	f.Types = list("types")
+	for _, t := range f.Types {
+		known := false
+		for _, et := range events.Types {
+			known = known || t == et
+		}
+		if !known {
+			return f, fmt.Errorf("unknown event type %q, want one of %s",
+				t, strings.Join(events.Types, ", "))
+		}
+	}
+	f.FactTypes = list("fact_type")
+	return f, nil
+}
+
+// handler, that sends node events as server-sent events
+// until client disconnects or node stops
+func (a *API) v1EventsHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	f, err := eventsFilter(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeV1Error(w, http.StatusInternalServerError, "internal", "Streaming is not supported")
+		return
+	}
+
+	sub := a.node.Subscribe(f)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAlivePeriod)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q}\n\n", lagged, lagged)
+				}
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				panic(err)
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+			if err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			// client has gone
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handler, that sends node events as websocket json messages
+// until client disconnects or node stops
+func (a *API) v1EventsWSHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	f, err := eventsFilter(r)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_filter", err.Error())
+		return
+	}
+
+	// origin is not checked, clients are not only browsers
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		sub := a.node.Subscribe(f)
+		defer sub.Close()
+
+		// client doesn't send anything, reading
+		// only notices that connection is closed
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			var msg []byte
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case e, ok := <-sub.Events():
+				if !ok {
+					if sub.Lagged() {
+						websocket.JSON.Send(ws, &events.Event{Type: lagged})
+					}
+					return
+				}
+				ws.SetWriteDeadline(time.Now().Add(eventWriteWait))
+				if websocket.JSON.Send(ws, e) != nil {
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	}}.ServeHTTP(w, r)
+}
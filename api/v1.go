@@ -0,0 +1,600 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/mempool"
+	"github.com/lavrs/blkchn/schema"
+)
+
+const (
+	// count of blocks returned by default
+	defaultLimit = 100
+	// max count of blocks returned at once
+	maxLimit = 1000
+	// count of blocks read from blockchain at once when streaming
+	streamBatch = 100
+	// content type of newline delimited json
+	ndjsonType = "application/x-ndjson"
+)
+
+// Error type for send error to clients
+type Error struct {
+	// http status code
+	Status int `json:"status"`
+	// machine readable error code
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse type for wrap error
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+// BlocksResponse type for send blocks
+type BlocksResponse struct {
+	Blocks []*chain.Block `json:"blocks,omitempty"`
+	// used instead of blocks in headers only mode
+	Headers []*chain.Header `json:"headers,omitempty"`
+	// latest block index
+	Height int `json:"height"`
+	// cursor of the next page, absent on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// FactResponse type for send fact and its status
+type FactResponse struct {
+	Fact *chain.Fact `json:"fact"`
+	*chain.FactStatus
+}
+
+// FactCreatedResponse type for send id of the created fact
+type FactCreatedResponse struct {
+	Id string `json:"id"`
+	// hash of fact data kept off-chain
+	Blob string `json:"blob,omitempty"`
+}
+
+// FactsResponse type for send found facts
+type FactsResponse struct {
+	Facts []*FactResponse `json:"facts"`
+	// cursor of the next page, absent on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// MiningResponse type for send mining block and its version
+type MiningResponse struct {
+	Job   uint64       `json:"job"`
+	Block *chain.Block `json:"block"`
+}
+
+// SchemasResponse type for send registered fact types
+type SchemasResponse struct {
+	Schemas []*schema.Entry `json:"schemas"`
+}
+
+// MempoolResponse type for send unconfirmed facts and pool usage
+type MempoolResponse struct {
+	mempool.Stats
+	Facts []*chain.Fact `json:"facts"`
+}
+
+// handler, that sends at most limit blocks starting from index from
+// or from cursor of the previous page, in headers only mode
+// facts are omitted, in ndjson format all blocks are streamed
+func (a *API) v1BlocksHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	from, ok := queryInt(r, "from", 0)
+	if !ok || from < 0 {
+		writeV1Error(w, http.StatusBadRequest, "invalid_from", "from must be a non-negative integer")
+		return
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		from, ok = a.decodeCursor(cursor)
+		if !ok {
+			writeV1Error(w, http.StatusBadRequest, "invalid_cursor",
+				"cursor is invalid or blockchain has changed since it was issued")
+			return
+		}
+	}
+	headers, err := strconv.ParseBool(r.URL.Query().Get("headers"))
+	if err != nil && r.URL.Query().Get("headers") != "" {
+		writeV1Error(w, http.StatusBadRequest, "invalid_headers", "headers must be a boolean")
+		return
+	}
+
+	stream := r.URL.Query().Get("format") == "ndjson" ||
+		strings.Contains(r.Header.Get("Accept"), ndjsonType)
+	// stream is not limited by default
+	defLimit := defaultLimit
+	if stream {
+		defLimit = -1
+	}
+	limit, ok := queryInt(r, "limit", defLimit)
+	if !ok || limit == 0 || limit < -1 || !stream && (limit < 0 || limit > maxLimit) {
+		writeV1Error(w, http.StatusBadRequest, "invalid_limit",
+			"limit must be an integer from 1 to "+strconv.Itoa(maxLimit))
+		return
+	}
+
+	if stream {
+		a.streamBlocks(w, from, limit, headers)
+		return
+	}
+
+	t := BlocksResponse{Height: a.node.Height()}
+	if headers {
+		// headers are kept for blocks received with snapshot too
+		t.Headers = a.node.HeadersRange(from, limit)
+		if n := len(t.Headers); n == limit && t.Headers[n-1].Index < t.Height {
+			t.Next = encodeCursor(t.Headers[n-1].Index, t.Headers[n-1].Hash)
+		}
+	} else {
+		// blocks before snapshot are skipped
+		t.Blocks = a.node.BlocksRange(from, limit)
+		if n := len(t.Blocks); n == limit && t.Blocks[n-1].Index < t.Height {
+			t.Next = encodeCursor(t.Blocks[n-1].Index, t.Blocks[n-1].Hash)
+		}
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// send blocks starting from index as newline delimited json,
+// negative limit means all blocks
+func (a *API) streamBlocks(w http.ResponseWriter, from, limit int, headers bool) {
+	w.Header().Set("Content-Type", ndjsonType)
+	w.WriteHeader(http.StatusOK)
+
+	var (
+		enc        = json.NewEncoder(w)
+		flusher, _ = w.(http.Flusher)
+	)
+	for limit != 0 {
+		batch := streamBatch
+		if limit > 0 && limit < batch {
+			batch = limit
+		}
+
+		var (
+			items []interface{}
+			last  int
+		)
+		if headers {
+			for _, h := range a.node.HeadersRange(from, batch) {
+				items, last = append(items, h), h.Index
+			}
+		} else {
+			for _, blk := range a.node.BlocksRange(from, batch) {
+				items, last = append(items, blk), blk.Index
+			}
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			err := enc.Encode(item)
+			if err != nil {
+				// client has gone
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		from = last + 1
+		if limit > 0 {
+			limit -= len(items)
+		}
+	}
+}
+
+// returns cursor pointing to the block after block with index and hash
+func encodeCursor(index int, hash string) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%d:%s", index+1, hash)))
+}
+
+// returns block index from cursor, cursor is invalid
+// if block before it is not in blockchain anymore
+func (a *API) decodeCursor(cursor string) (int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+
+	var (
+		from int
+		hash string
+	)
+	_, err = fmt.Sscanf(string(data), "%d:%s", &from, &hash)
+	if err != nil || from < 1 {
+		return 0, false
+	}
+
+	headers := a.node.HeadersRange(from-1, 1)
+	return from, len(headers) == 1 && headers[0].Hash == hash
+}
+
+// handler, that sends block by its index or hash
+func (a *API) v1BlockHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var (
+		id  = strings.TrimPrefix(r.URL.Path, "/v1/blocks/")
+		blk *chain.Block
+		ok  bool
+	)
+	if index, err := strconv.Atoi(id); err == nil {
+		blk, ok = a.node.Block(index)
+		if !ok && a.pruned(index) {
+			writeV1Error(w, http.StatusGone, "block_pruned",
+				"Facts of block are pruned, its header is kept")
+			return
+		}
+	} else {
+		blk, ok = a.node.BlockByHash(id)
+	}
+	if !ok {
+		writeV1Error(w, http.StatusNotFound, "block_not_found", "Block not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, blk)
+}
+
+// reports whether block with index is in blockchain without facts
+func (a *API) pruned(index int) bool {
+	_, ok := a.node.Block(index)
+	return !ok && len(a.node.HeadersRange(index, 1)) == 1
+}
+
+// handler, that when requested by method get sends confirmed facts
+// matching query and, if requested by method post, takes a new
+// unconfirmed fact of type and author from query and sends its id
+func (a *API) v1FactsHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		a.queryFacts(w, r)
+		return
+	}
+	if r.URL.Query().Get("blob") == "true" {
+		a.submitBlob(w, r)
+		return
+	}
+
+	var fact interface{}
+	err := json.NewDecoder(r.Body).Decode(&fact)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_fact", "Fact must be valid json")
+		return
+	}
+
+	t, err := a.node.SubmitFact(r.URL.Query().Get("type"), r.URL.Query().Get("author"), fact)
+	if err != nil {
+		code, errCode := submitError(err)
+		writeV1Error(w, code, errCode, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/v1/facts/"+t.Id)
+	writeJSON(w, http.StatusCreated, FactCreatedResponse{Id: t.Id})
+}
+
+// take a new unconfirmed fact with body kept off-chain
+// as blob and send fact id and blob hash
+func (a *API) submitBlob(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, blob.MaxSize+1))
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_fact", "Failed to read fact data")
+		return
+	}
+
+	t, err := a.node.SubmitBlob(r.URL.Query().Get("type"), r.URL.Query().Get("author"), data)
+	if err != nil {
+		code, errCode := submitError(err)
+		writeV1Error(w, code, errCode, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/v1/facts/"+t.Id)
+	writeJSON(w, http.StatusCreated, FactCreatedResponse{Id: t.Id, Blob: t.Blob})
+}
+
+// returns handler, that sends fact with id from path after prefix
+// and its status: pending in unconfirmed facts, in mining block
+// or confirmed in block with count of confirmations
+func (a *API) factStatusHandler(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+
+		fact, status, ok := a.node.FactStatus(strings.TrimPrefix(r.URL.Path, prefix))
+		if !ok {
+			writeV1Error(w, http.StatusNotFound, "fact_not_found", "Fact not found")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, FactResponse{Fact: fact, FactStatus: status})
+	}
+}
+
+// handler, that sends confirmed facts matching query
+func (a *API) queryFactsHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	a.queryFacts(w, r)
+}
+
+// send at most limit confirmed facts filtered by type, author, block
+// time range and top level fields (field.name=value) in blockchain order
+func (a *API) queryFacts(w http.ResponseWriter, r *http.Request) {
+	var (
+		params = r.URL.Query()
+		q      = chain.Query{
+			Type:   params.Get("type"),
+			Author: params.Get("author"),
+			Fields: make(map[string]string),
+		}
+		ok  bool
+		err error
+	)
+	for name, values := range params {
+		if strings.HasPrefix(name, "field.") && name != "field." {
+			q.Fields[strings.TrimPrefix(name, "field.")] = values[0]
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if s := params.Get(t.name); s != "" {
+			*t.dst, err = time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				writeV1Error(w, http.StatusBadRequest, "invalid_"+t.name,
+					t.name+" must be RFC 3339 time")
+				return
+			}
+		}
+	}
+
+	q.Limit, ok = queryInt(r, "limit", defaultLimit)
+	if !ok || q.Limit < 1 || q.Limit > maxLimit {
+		writeV1Error(w, http.StatusBadRequest, "invalid_limit",
+			"limit must be an integer from 1 to "+strconv.Itoa(maxLimit))
+		return
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		q.After, ok = a.decodeFactCursor(cursor)
+		if !ok {
+			writeV1Error(w, http.StatusBadRequest, "invalid_cursor",
+				"cursor is invalid or blockchain has changed since it was issued")
+			return
+		}
+	}
+
+	var (
+		found  = a.node.QueryFacts(q)
+		height = a.node.Height()
+		t      = FactsResponse{Facts: make([]*FactResponse, len(found))}
+	)
+	for i, f := range found {
+		index := f.Block.Index
+		t.Facts[i] = &FactResponse{Fact: f.Fact, FactStatus: &chain.FactStatus{
+			Status:        chain.CONFIRMED,
+			BlockIndex:    &index,
+			Confirmations: height - index + 1,
+		}}
+	}
+	if len(found) == q.Limit {
+		last := found[len(found)-1]
+		t.Next = base64.RawURLEncoding.EncodeToString([]byte(
+			fmt.Sprintf("%d:%d:%s", last.Ref.Block, last.Ref.Pos, last.Block.Hash)))
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// returns fact position from cursor, cursor is invalid
+// if block of the position is not in blockchain anymore
+func (a *API) decodeFactCursor(cursor string) (*chain.FactRef, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, false
+	}
+
+	var (
+		ref  chain.FactRef
+		hash string
+	)
+	_, err = fmt.Sscanf(string(data), "%d:%d:%s", &ref.Block, &ref.Pos, &hash)
+	if err != nil || ref.Block < 0 || ref.Pos < 0 {
+		return nil, false
+	}
+
+	blk, ok := a.node.Block(ref.Block)
+	return &ref, ok && blk.Hash == hash
+}
+
+// returns status code and error code for error of fact submit
+func submitError(err error) (int, string) {
+	switch err {
+	case mempool.ErrFactTooLarge:
+		return http.StatusRequestEntityTooLarge, "fact_too_large"
+	case blob.ErrTooLarge:
+		return http.StatusRequestEntityTooLarge, "blob_too_large"
+	case blob.ErrFull:
+		return http.StatusInsufficientStorage, "blob_store_full"
+	case mempool.ErrPoolFull:
+		return http.StatusServiceUnavailable, "mempool_full"
+	case mempool.ErrDuplicate:
+		return http.StatusConflict, "duplicate_fact"
+	case mempool.ErrConfirmed:
+		return http.StatusConflict, "confirmed_fact"
+	case schema.ErrNullFact:
+		return http.StatusBadRequest, "invalid_fact"
+	case schema.ErrUnknownType:
+		return http.StatusUnprocessableEntity, "unknown_type"
+	case schema.ErrTypeRequired:
+		return http.StatusUnprocessableEntity, "type_required"
+	}
+	if _, ok := err.(*schema.ValidationError); ok {
+		return http.StatusUnprocessableEntity, "schema_mismatch"
+	}
+	return http.StatusServiceUnavailable, "unavailable"
+}
+
+// handler, that sends mining block and its version
+func (a *API) v1MiningHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	blk, job := a.node.MiningJob()
+	if blk == nil {
+		writeV1Error(w, http.StatusServiceUnavailable, "unavailable", "Mining block is not received yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, MiningResponse{Job: job, Block: blk})
+}
+
+// handler, that sends registered fact types
+func (a *API) v1SchemasHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SchemasResponse{Schemas: a.node.Schemas()})
+}
+
+// handler, that when requested by method get sends fact type
+// with its schema and, if requested by method put, registers it.
+// Registered schema can't be changed
+func (a *API) v1SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+
+	typ := strings.TrimPrefix(r.URL.Path, "/v1/schemas/")
+	if r.Method == http.MethodGet {
+		e, ok := a.node.Schema(typ)
+		if !ok {
+			writeV1Error(w, http.StatusNotFound, "schema_not_found", "Schema not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, e)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return
+	}
+	e, created, err := a.node.RegisterSchema(typ, data)
+	switch {
+	case err == schema.ErrInvalidType:
+		writeV1Error(w, http.StatusBadRequest, "invalid_type", err.Error())
+	case err == schema.ErrTypeExists:
+		writeV1Error(w, http.StatusConflict, "schema_exists", err.Error())
+	case err != nil && e == nil:
+		writeV1Error(w, http.StatusBadRequest, "invalid_schema", err.Error())
+	case err != nil:
+		// registered, but not saved
+		writeV1Error(w, http.StatusInternalServerError, "internal", err.Error())
+	case created:
+		writeJSON(w, http.StatusCreated, e)
+	default:
+		writeJSON(w, http.StatusOK, e)
+	}
+}
+
+// handler, that sends unconfirmed facts with pool usage and limits
+func (a *API) mempoolHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	facts := a.node.PendingFacts()
+	stats := a.node.MempoolStats()
+	// count is taken from facts, pool may change between calls
+	stats.Count = len(facts)
+	writeJSON(w, http.StatusOK, MempoolResponse{Stats: stats, Facts: facts})
+}
+
+// check request method, sends error if it is not allowed
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeV1Error(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	return false
+}
+
+// returns integer query parameter or default value if it is absent
+func queryInt(r *http.Request, name string, def int) (int, bool) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, true
+	}
+
+	i, err := strconv.Atoi(s)
+	return i, err == nil
+}
+
+// send json with status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// send error object with status code
+func writeV1Error(w http.ResponseWriter, code int, errCode, msg string) {
+	writeJSON(w, code, ErrorResponse{&Error{Status: code, Code: errCode, Message: msg}})
+}
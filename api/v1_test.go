@@ -0,0 +1,111 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/api"
+	"github.com/lavrs/blkchn/config"
+	"github.com/lavrs/blkchn/node"
+)
+
+// starts root node on free local port and returns its api url
+func startNode(t *testing.T) (string, func()) {
+	cfg := config.Default()
+	cfg.HTTPBind, cfg.WSBind = "127.0.0.1", "127.0.0.1"
+	cfg.HTTPPort, cfg.WSPort = "0", "0"
+	n, err := node.New(cfg)
+	if err == nil {
+		err = n.Start()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "http://" + n.HTTPAddr(), func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+	}
+}
+
+// sends request and decodes response into v, returns status code
+func request(t *testing.T, method, url, body string, v interface{}) (int, http.Header) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		err = json.NewDecoder(resp.Body).Decode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, resp.Header
+}
+
+func TestV1Errors(t *testing.T) {
+	url, stop := startNode(t)
+	defer stop()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "invalid limit", method: http.MethodGet, path: "/v1/blocks?limit=0", status: http.StatusBadRequest, code: "invalid_limit"},
+		{name: "invalid cursor", method: http.MethodGet, path: "/v1/blocks?cursor=x", status: http.StatusBadRequest, code: "invalid_cursor"},
+		{name: "unknown block", method: http.MethodGet, path: "/v1/blocks/5", status: http.StatusNotFound, code: "block_not_found"},
+		{name: "unknown fact", method: http.MethodGet, path: "/v1/facts/x", status: http.StatusNotFound, code: "fact_not_found"},
+		{name: "invalid fact", method: http.MethodPost, path: "/v1/facts", body: "{", status: http.StatusBadRequest, code: "invalid_fact"},
+		{name: "method", method: http.MethodDelete, path: "/v1/blocks", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp api.ErrorResponse
+			status, _ := request(t, tt.method, url+tt.path, tt.body, &resp)
+			if status != tt.status || resp.Error == nil || resp.Error.Status != tt.status || resp.Error.Code != tt.code {
+				t.Fatalf("want %d %s, got %d %+v", tt.status, tt.code, status, resp.Error)
+			}
+		})
+	}
+}
+
+func TestV1Facts(t *testing.T) {
+	url, stop := startNode(t)
+	defer stop()
+
+	var created api.FactCreatedResponse
+	status, header := request(t, http.MethodPost, url+"/v1/facts?author=bob", `{"a": 1}`, &created)
+	if status != http.StatusCreated || created.Id == "" || header.Get("Location") != "/v1/facts/"+created.Id {
+		t.Fatalf("want fact created, got %d %+v", status, created)
+	}
+
+	var fact api.FactResponse
+	status, _ = request(t, http.MethodGet, url+header.Get("Location"), "", &fact)
+	if status != http.StatusOK || fact.Fact == nil || fact.Fact.Id != created.Id || fact.Fact.Author != "bob" {
+		t.Fatalf("want created fact, got %d %+v", status, fact.Fact)
+	}
+	if fact.FactStatus == nil || fact.Status == "confirmed" {
+		t.Fatalf("want unconfirmed fact, got %+v", fact.FactStatus)
+	}
+
+	var blocks api.BlocksResponse
+	status, _ = request(t, http.MethodGet, url+"/v1/blocks", "", &blocks)
+	if status != http.StatusOK || blocks.Height != 0 || len(blocks.Blocks) != 1 {
+		t.Fatalf("want genesis block, got %d %+v", status, blocks)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/lavrs/blkchn/webhook"
+)
+
+// WebhooksResponse type for send webhooks
+type WebhooksResponse struct {
+	Webhooks []*webhook.Hook `json:"webhooks"`
+}
+
+// DeliveriesResponse type for send webhook delivery log
+type DeliveriesResponse struct {
+	Deliveries []*webhook.Delivery `json:"deliveries"`
+}
+
+// handler, that when requested by method get sends webhooks and,
+// if requested by method post, creates webhook and sends it with secret
+func (a *API) v1WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, WebhooksResponse{Webhooks: a.node.Webhooks().Hooks()})
+		return
+	}
+
+	var h webhook.Hook
+	err := json.NewDecoder(r.Body).Decode(&h)
+	if err != nil {
+		writeV1Error(w, http.StatusBadRequest, "invalid_webhook", "Webhook must be valid json")
+		return
+	}
+
+	t, err := a.node.Webhooks().Add(h)
+	switch {
+	case err == webhook.ErrInvalidURL:
+		writeV1Error(w, http.StatusBadRequest, "invalid_url", err.Error())
+	case err == webhook.ErrInvalidConfirmations:
+		writeV1Error(w, http.StatusBadRequest, "invalid_confirmations", err.Error())
+	case err != nil:
+		// created, but not saved
+		writeV1Error(w, http.StatusInternalServerError, "internal", err.Error())
+	default:
+		w.Header().Set("Location", "/v1/webhooks/"+t.Id)
+		writeJSON(w, http.StatusCreated, t)
+	}
+}
+
+// handler, that sends webhook or its delivery log
+// and, if requested by method delete, removes webhook
+func (a *API) v1WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	a.log.Println(r.RemoteAddr, r.URL.Path, r.Method)
+
+	var (
+		id         = strings.TrimPrefix(r.URL.Path, "/v1/webhooks/")
+		deliveries = strings.HasSuffix(id, "/deliveries")
+	)
+	id = strings.TrimSuffix(id, "/deliveries")
+
+	methods := []string{http.MethodGet, http.MethodDelete}
+	if deliveries {
+		methods = methods[:1]
+	}
+	if !allowMethods(w, r, methods...) {
+		return
+	}
+
+	hooks := a.node.Webhooks()
+	h, ok := hooks.Hook(id)
+	if !ok {
+		writeV1Error(w, http.StatusNotFound, "webhook_not_found", "Webhook not found")
+		return
+	}
+
+	switch {
+	case deliveries:
+		writeJSON(w, http.StatusOK, DeliveriesResponse{Deliveries: hooks.Deliveries(id)})
+	case r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h)
+	default:
+		_, err := hooks.Remove(id)
+		if err != nil {
+			writeV1Error(w, http.StatusInternalServerError, "internal", err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,157 @@
+// Package blob implements content-addressed store of fact
+// data kept off-chain, blobs are named by sha256 of their data.
+package blob
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+const (
+	// MaxSize is max size of blob, blob is sent to
+	// other nodes in one message encoded in base64
+	MaxSize = 16 << 20
+	// MaxMemory is max total size of blobs kept in memory
+	MaxMemory = 256 << 20
+	// name of blobs directory in data dir
+	dirName = "blobs"
+)
+
+var (
+	// ErrNotFound means that store doesn't have blob
+	ErrNotFound = errors.New("blob not found")
+	// ErrCorrupt means that blob data doesn't match its hash,
+	// such blob is removed from store
+	ErrCorrupt = errors.New("blob doesn't match its hash")
+	// ErrTooLarge means that blob is larger than allowed
+	ErrTooLarge = errors.New("blob is too large")
+	// ErrFull means that memory of store has no room for blob
+	ErrFull = errors.New("blob store is full")
+)
+
+// blob hash is sha256 in hex
+var hashFormat = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Store type for keep blobs in data dir or
+// in memory if data dir is not set
+type Store struct {
+	// blobs directory, empty if blobs are kept in memory
+	dir string
+
+	mu  sync.RWMutex
+	mem map[string][]byte
+	// total size of blobs in memory and its limit
+	memBytes int
+	memLimit int
+}
+
+// Open returns store of blobs of data dir
+func Open(dataDir string) (*Store, error) {
+	if dataDir == "" {
+		return &Store{mem: make(map[string][]byte), memLimit: MaxMemory}, nil
+	}
+
+	dir := filepath.Join(dataDir, dirName)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Hash returns blob hash of data
+func Hash(data []byte) string {
+	return chain.CalcHash(string(data))
+}
+
+// IsHash reports whether s is valid blob hash
+func IsHash(s string) bool {
+	return hashFormat.MatchString(s)
+}
+
+// Put saves data and returns its hash, store
+// in memory refuses blobs above its limit
+func (s *Store) Put(data []byte) (string, error) {
+	hash := Hash(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		if _, ok := s.mem[hash]; ok {
+			return hash, nil
+		}
+		if s.memBytes+len(data) > s.memLimit {
+			return "", ErrFull
+		}
+		s.mem[hash] = data
+		s.memBytes += len(data)
+		return hash, nil
+	}
+
+	path := filepath.Join(s.dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	err := ioutil.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return "", err
+	}
+	return hash, os.Rename(path+".tmp", path)
+}
+
+// Get returns blob data by hash, data is checked against hash
+func (s *Store) Get(hash string) ([]byte, error) {
+	if !IsHash(hash) {
+		return nil, ErrNotFound
+	}
+
+	s.mu.RLock()
+	var (
+		data []byte
+		err  error
+	)
+	if s.dir == "" {
+		var ok bool
+		data, ok = s.mem[hash]
+		if !ok {
+			err = ErrNotFound
+		}
+	} else {
+		data, err = ioutil.ReadFile(filepath.Join(s.dir, hash))
+		if os.IsNotExist(err) {
+			err = ErrNotFound
+		}
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if Hash(data) != hash {
+		s.remove(hash)
+		return nil, ErrCorrupt
+	}
+	return data, nil
+}
+
+// remove blob
+func (s *Store) remove(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		if data, ok := s.mem[hash]; ok {
+			s.memBytes -= len(data)
+			delete(s.mem, hash)
+		}
+		return
+	}
+	os.Remove(filepath.Join(s.dir, hash))
+}
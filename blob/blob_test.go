@@ -0,0 +1,55 @@
+package blob
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	s, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.memLimit = 10
+
+	a := bytes.Repeat([]byte("a"), 6)
+	hash, err := s.Put(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same blob takes no more room
+	_, err = s.Put(a)
+	if err != nil {
+		t.Fatalf("want the same blob stored again, got %v", err)
+	}
+	_, err = s.Put(bytes.Repeat([]byte("b"), 6))
+	if err != ErrFull {
+		t.Fatalf("want error %v, got %v", ErrFull, err)
+	}
+
+	// removed blob frees its room
+	s.remove(hash)
+	_, err = s.Put(bytes.Repeat([]byte("b"), 6))
+	if err != nil {
+		t.Fatalf("want blob stored after remove, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := s.Put([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get(hash)
+	if err != nil || string(data) != "data" {
+		t.Fatalf("want blob data, got %q and %v", data, err)
+	}
+	_, err = s.Get(Hash([]byte("other")))
+	if err != ErrNotFound {
+		t.Fatalf("want error %v, got %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,111 @@
+package chain
+
+// Base type for store blockchain state before its first kept block,
+// blockchain received with snapshot doesn't have earlier blocks
+type Base struct {
+	// headers of blocks before the first kept block
+	Headers []*Header `json:"headers"`
+	// fact id -> block index of facts of these blocks
+	Facts map[string]int `json:"facts"`
+}
+
+// NewWithBase returns blockchain with blocks following base,
+// base is trusted, so it must be verified before
+func NewWithBase(base *Base, blocks []*Block) *Chain {
+	c := &Chain{base: base}
+	c.setBlocks(blocks)
+	return c
+}
+
+// Base returns state before the first kept block,
+// nil if blockchain has all blocks
+func (c *Chain) Base() *Base {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.base
+}
+
+// Restore replaces blockchain with blocks following base,
+// base is trusted, so it must be verified before
+func (c *Chain) Restore(base *Base, blocks []*Block) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.store != nil {
+		err := c.store.Replace(base, blocks)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.base = base
+	c.setBlocks(blocks)
+	return nil
+}
+
+// returns index of the first kept block, lock must be held
+func (c *Chain) first() int {
+	if c.base == nil {
+		return 0
+	}
+	return len(c.base.Headers)
+}
+
+// returns header of block by index, lock must be held
+func (c *Chain) header(index int) (*Header, bool) {
+	if index >= 0 && index < c.first() {
+		return c.base.Headers[index], true
+	}
+	blk, ok := c.get(index)
+	if !ok {
+		return nil, false
+	}
+	return blk.Header(), true
+}
+
+// Headers returns at most limit block headers starting from index,
+// headers of blocks before the first kept block are included
+func (c *Chain) Headers(from, limit int) []*Header {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var headers []*Header
+	for i := from; i <= c.height() && len(headers) < limit; i++ {
+		h, ok := c.header(i)
+		if !ok {
+			break
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
+
+// FactIndex returns latest block header and
+// block indexes of all confirmed facts by id
+func (c *Chain) FactIndex() (*Header, map[string]int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.blocks) == 0 {
+		return nil, nil
+	}
+	facts := make(map[string]int, len(c.byFact))
+	for id, i := range c.byFact {
+		facts[id] = i
+	}
+	return c.blocks[len(c.blocks)-1].Header(), facts
+}
+
+// checks that blocks follow block of header prev and each
+// other, their hashes are valid, their nonces solve them
+// and their complexity follows difficulty
+func isValidPart(prev *Header, blocks []*Block, d Difficulty) bool {
+	for _, blk := range blocks {
+		if !follows(prev, blk, d) {
+			return false
+		}
+		prev = blk.Header()
+	}
+	return true
+}
@@ -0,0 +1,588 @@
+// Package chain implements blocks, facts and the blockchain itself.
+package chain
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInvalidBlock means that block can't be appended to the blockchain
+var ErrInvalidBlock = errors.New("invalid block")
+
+// Fact type for store fact
+type Fact struct {
+	// has unique id for identify
+	Id string `json:"id"`
+	// registered fact type like namespace/name,
+	// empty for facts without schema
+	Type string `json:"type,omitempty"`
+	// name of fact producer given on submit, not verified
+	Author string       `json:"author,omitempty"`
+	Fact   *interface{} `json:"fact,omitempty"`
+	// sha256 of fact data kept off-chain in blob store,
+	// fact doesn't have data then
+	Blob string `json:"blob,omitempty"`
+}
+
+// Block type for store block
+type Block struct {
+	Index int `json:"index"`
+	// calculated from block info
+	Hash string `json:"hash"`
+	// point to previous block hash
+	PrevHash  string    `json:"prev_hash"`
+	Timestamp time.Time `json:"timestamp"`
+	Facts     []*Fact   `json:"facts,omitempty"`
+	// mining complexity
+	Complexity int `json:"complexity"`
+	// random number to form a hash for successful mining
+	Nonce string `json:"nonce"`
+}
+
+// Header type for send block without facts
+type Header struct {
+	Index      int       `json:"index"`
+	Hash       string    `json:"hash"`
+	PrevHash   string    `json:"prev_hash"`
+	Timestamp  time.Time `json:"timestamp"`
+	Complexity int       `json:"complexity"`
+	Nonce      string    `json:"nonce"`
+	// count of block facts
+	FactCount int `json:"fact_count"`
+	// merkle root of block facts
+	FactsRoot string `json:"facts_root,omitempty"`
+}
+
+// VMBlocks type for send valid / mining block
+type VMBlocks struct {
+	ValidBlock  *Block `json:"valid_block,omitempty"`
+	MiningBlock *Block `json:"mining_block"`
+}
+
+const (
+	// PENDING means that fact is in unconfirmed facts
+	PENDING = "pending"
+	// MINING means that fact is in mining block
+	MINING = "mining"
+	// CONFIRMED means that fact is in blockchain
+	CONFIRMED = "confirmed"
+)
+
+// FactStatus type for send where fact is
+type FactStatus struct {
+	// pending, mining or confirmed
+	Status string `json:"status"`
+	// index of block fact is confirmed in
+	BlockIndex *int `json:"block_index,omitempty"`
+	// count of blocks starting from fact block
+	Confirmations int `json:"confirmations,omitempty"`
+	// fact body is pruned, only id and block are kept
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+// Difficulty type for store mining complexity rules
+type Difficulty struct {
+	// complexity increases if blocks are created
+	// faster than this interval, otherwise decreases
+	BlockInterval time.Duration
+	MinComplexity int
+	MaxComplexity int
+}
+
+// String returns block data in string, facts are
+// committed by their merkle root
+func (b *Block) String() string {
+	return b.Header().String()
+}
+
+// returns fact data in string, each field is written
+// with its kind and length, so bytes of one field can't
+// be moved to another one without changing fact hash
+func factString(fact *Fact) string {
+	data := field('i', fact.Id) + field('t', fact.Type) + field('a', fact.Author)
+	// fact has either hash of data kept off-chain or data itself,
+	// facts without data are not accepted, but received blocks may have them
+	if fact.Blob != "" {
+		data += field('b', fact.Blob)
+	}
+	if fact.Fact != nil {
+		data += field('d', fmt.Sprint(*fact.Fact))
+	}
+	return data
+}
+
+// returns value prefixed with its kind and length
+func field(kind byte, value string) string {
+	return string(kind) + strconv.Itoa(len(value)) + ":" + value
+}
+
+// Header returns block header
+func (b *Block) Header() *Header {
+	return &Header{
+		Index:      b.Index,
+		Hash:       b.Hash,
+		PrevHash:   b.PrevHash,
+		Timestamp:  b.Timestamp,
+		Complexity: b.Complexity,
+		Nonce:      b.Nonce,
+		FactCount:  len(b.Facts),
+		FactsRoot:  MerkleRoot(b.Facts),
+	}
+}
+
+// CalcHash returns block hash. Block nonce is not a part of
+// block hash, it is kept to check proof of work later
+func (b *Block) CalcHash() string {
+	return b.Header().CalcHash()
+}
+
+// Solved checks that hash of block with its nonce
+// has enough leading zeros
+func (b *Block) Solved() bool {
+	return b.Header().Solved()
+}
+
+// String returns data of header block, it is the same as
+// block data, so block hash can be checked by its header
+func (h *Header) String() string {
+	return field('p', h.PrevHash) + field('t', h.Timestamp.String()) +
+		field('n', h.Nonce) + field('i', strconv.Itoa(h.Index)) +
+		field('c', strconv.Itoa(h.Complexity)) + field('r', h.FactsRoot)
+}
+
+// CalcHash returns hash of header block
+func (h *Header) CalcHash() string {
+	t := *h
+	t.Nonce = ""
+	return CalcHash(t.String())
+}
+
+// Solved checks that hash of header block with its
+// nonce has enough leading zeros
+func (h *Header) Solved() bool {
+	// calc count first zeros
+	countZero := 0
+	for _, s := range CalcHash(h.String()) {
+		if s == '0' {
+			countZero++
+			continue
+		}
+		break
+	}
+
+	return countZero >= h.Complexity
+}
+
+// CalcHash returns sha256 hash of data in hex
+func CalcHash(data string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+}
+
+// Genesis returns new genesis block
+func Genesis() *Block {
+	// timestamps are in utc and without monotonic clock
+	// to get the same block string after json transfer
+	blk := &Block{
+		Timestamp: time.Now().UTC(),
+	}
+	// calc hash for genesis block
+	blk.Hash = CalcHash(blk.String())
+
+	return blk
+}
+
+// NextBlock creates next mining block after latest block with facts
+func NextBlock(latestBlk *Block, facts []*Fact, d Difficulty) *Block {
+	// create new block
+	blk := &Block{
+		Index:     latestBlk.Index + 1,
+		PrevHash:  latestBlk.Hash,
+		Timestamp: time.Now().UTC(),
+		Facts:     facts,
+	}
+
+	blk.Complexity = d.Next(latestBlk.Complexity, blk.Timestamp.Sub(latestBlk.Timestamp))
+
+	blk.Hash = CalcHash(blk.String())
+	return blk
+}
+
+// Next returns complexity of block created in interval after block
+// of complexity. It increases if the interval is less than block
+// interval, otherwise decreases, but stays in configured bounds
+func (d Difficulty) Next(complexity int, interval time.Duration) int {
+	if interval < d.BlockInterval {
+		complexity++
+	} else {
+		complexity--
+	}
+	if complexity < d.MinComplexity {
+		complexity = d.MinComplexity
+	}
+	if complexity > d.MaxComplexity {
+		complexity = d.MaxComplexity
+	}
+	return complexity
+}
+
+// IsValidNext checks that block can follow previous block,
+// its nonce solves it and its complexity follows difficulty
+func IsValidNext(prev, blk *Block, d Difficulty) bool {
+	// facts root of previous block is not needed
+	return follows(&Header{
+		Index:      prev.Index,
+		Hash:       prev.Hash,
+		Timestamp:  prev.Timestamp,
+		Complexity: prev.Complexity,
+	}, blk, d)
+}
+
+// checks that block can follow block of header
+func follows(prev *Header, blk *Block, d Difficulty) bool {
+	h := blk.Header()
+	return prev.Index+1 == h.Index &&
+		prev.Hash == h.PrevHash &&
+		h.CalcHash() == h.Hash &&
+		h.Solved() &&
+		h.Complexity == d.Next(prev.Complexity, h.Timestamp.Sub(prev.Timestamp))
+}
+
+// IsValidChain checks each block of the blockchain,
+// genesis block is not mined
+func IsValidChain(blocks []*Block, d Difficulty) bool {
+	if len(blocks) == 0 || blocks[0].CalcHash() != blocks[0].Hash {
+		return false
+	}
+
+	for i := 1; i < len(blocks); i++ {
+		if !IsValidNext(blocks[i-1], blocks[i], d) {
+			return false
+		}
+	}
+	return true
+}
+
+// returns work of block of complexity, count of hashes
+// expected to solve it, 16^complexity
+func work(complexity int) *big.Int {
+	if complexity < 0 {
+		complexity = 0
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(4*complexity))
+}
+
+// Chain type for store blockchain
+type Chain struct {
+	mu     sync.RWMutex
+	blocks []*Block
+	// block hash -> block index
+	byHash map[string]int
+	// fact id -> block index
+	byFact map[string]int
+	// query indexes: fact type, author and
+	// field value -> facts positions in order
+	byType   map[string][]FactRef
+	byAuthor map[string][]FactRef
+	byField  map[string][]FactRef
+	// blob hash -> block index
+	byBlob map[string]int
+	// state before the first kept block,
+	// nil if blockchain has all blocks
+	base *Base
+	// persists blocks, nil if blocks are kept only in memory
+	store Store
+	// rules of complexity of appended blocks
+	difficulty Difficulty
+}
+
+// Store type for persist blocks of the blockchain
+type Store interface {
+	// Append writes block appended to the blockchain
+	Append(blk *Block) error
+	// Replace writes all blocks of replaced blockchain
+	// and its base, base is nil for full blockchain
+	Replace(base *Base, blocks []*Block) error
+}
+
+// New returns blockchain with blocks
+func New(blocks []*Block) *Chain {
+	c := &Chain{}
+	c.setBlocks(blocks)
+	return c
+}
+
+// SetStore sets storage of blocks, blocks are written
+// to it before they are appended or replaced
+func (c *Chain) SetStore(s Store) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store = s
+}
+
+// SetDifficulty sets complexity rules, complexity
+// of appended or received blocks must follow them
+func (c *Chain) SetDifficulty(d Difficulty) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.difficulty = d
+}
+
+// set blocks and rebuild indexes, lock must be held
+func (c *Chain) setBlocks(blocks []*Block) {
+	c.blocks = blocks
+	c.byHash = make(map[string]int, len(blocks))
+	c.byFact = make(map[string]int)
+	if c.base != nil {
+		for id, i := range c.base.Facts {
+			c.byFact[id] = i
+		}
+	}
+	c.byType = make(map[string][]FactRef)
+	c.byAuthor = make(map[string][]FactRef)
+	c.byField = make(map[string][]FactRef)
+	c.byBlob = make(map[string]int)
+	for _, blk := range blocks {
+		c.index(blk)
+	}
+}
+
+// add block to indexes, lock must be held
+func (c *Chain) index(blk *Block) {
+	c.byHash[blk.Hash] = blk.Index
+	for _, fact := range blk.Facts {
+		c.byFact[fact.Id] = blk.Index
+		if fact.Blob != "" {
+			c.byBlob[fact.Blob] = blk.Index
+		}
+	}
+	c.indexFacts(blk)
+}
+
+// Height returns latest block index or -1
+// if blockchain has no blocks yet
+func (c *Chain) Height() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.height()
+}
+
+// returns latest block index, lock must be held
+func (c *Chain) height() int {
+	return c.first() + len(c.blocks) - 1
+}
+
+// Latest returns latest block
+func (c *Chain) Latest() *Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.blocks) == 0 {
+		return nil
+	}
+	return c.blocks[len(c.blocks)-1]
+}
+
+// Block returns block by index
+func (c *Chain) Block(index int) (*Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.get(index)
+}
+
+// returns kept block by index, lock must be held
+func (c *Chain) get(index int) (*Block, bool) {
+	i := index - c.first()
+	if i < 0 || i >= len(c.blocks) {
+		return nil, false
+	}
+	return c.blocks[i], true
+}
+
+// BlockByHash returns block by its hash
+func (c *Chain) BlockByHash(hash string) (*Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.byHash[hash]
+	if !ok {
+		return nil, false
+	}
+	return c.get(i)
+}
+
+// Range returns at most limit blocks starting from index,
+// blocks before the first kept block are skipped
+func (c *Chain) Range(from, limit int) []*Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	from -= c.first()
+	if from < 0 {
+		from = 0
+	}
+	if from >= len(c.blocks) || limit <= 0 {
+		return nil
+	}
+	to := from + limit
+	if to > len(c.blocks) {
+		to = len(c.blocks)
+	}
+	return append([]*Block(nil), c.blocks[from:to]...)
+}
+
+// FindFact returns confirmed fact by id and its block. Fact of block
+// before the first kept block is returned only with id and its block
+// without facts
+func (c *Chain) FindFact(id string) (*Fact, *Block) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.byFact[id]
+	if !ok {
+		return nil, nil
+	}
+	blk, ok := c.get(i)
+	if !ok {
+		h := c.base.Headers[i]
+		return &Fact{Id: id}, &Block{
+			Index:      h.Index,
+			Hash:       h.Hash,
+			PrevHash:   h.PrevHash,
+			Timestamp:  h.Timestamp,
+			Complexity: h.Complexity,
+			Nonce:      h.Nonce,
+		}
+	}
+	for _, fact := range blk.Facts {
+		if fact.Id == id {
+			return fact, blk
+		}
+	}
+	return nil, nil
+}
+
+// HasBlob checks that fact of kept block has data in blob with hash
+func (c *Chain) HasBlob(hash string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.byBlob[hash]
+	return ok
+}
+
+// Blocks returns all kept blocks
+func (c *Chain) Blocks() []*Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]*Block(nil), c.blocks...)
+}
+
+// Append validates block and appends it to the blockchain
+func (c *Chain) Append(blk *Block) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.blocks) == 0 || !IsValidNext(c.blocks[len(c.blocks)-1], blk, c.difficulty) {
+		return ErrInvalidBlock
+	}
+	if c.store != nil {
+		err := c.store.Append(blk)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.blocks = append(c.blocks, blk)
+	c.index(blk)
+	return nil
+}
+
+// Replace replaces blocks with received ones if they have
+// more work and are valid, returns false if blocks are not
+// taken. Work of blocks is count of hashes expected to solve
+// them, so longer blocks of lower complexity don't win.
+// Received blocks may start after genesis block, then they
+// replace blocks starting from their first block if it
+// follows kept block or the last block of base
+func (c *Chain) Replace(blocks []*Block) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(blocks) == 0 {
+		return false, nil
+	}
+
+	base := c.base
+	from := blocks[0].Index
+	var prev *Header
+	if from > 0 {
+		var ok bool
+		prev, ok = c.header(from - 1)
+		if !ok || from < c.first() || prev.Hash != blocks[0].PrevHash {
+			// blocks don't follow ours
+			return false, nil
+		}
+	}
+	if c.work(from).Cmp(blocksWork(blocks)) >= 0 {
+		return false, nil
+	}
+
+	if from == 0 {
+		// full blockchain
+		if !IsValidChain(blocks, c.difficulty) {
+			return false, ErrInvalidBlock
+		}
+		base = nil
+	} else {
+		if !isValidPart(prev, blocks, c.difficulty) {
+			return false, ErrInvalidBlock
+		}
+		kept := c.blocks[:from-c.first()]
+		blocks = append(append([]*Block(nil), kept...), blocks...)
+	}
+	if c.store != nil {
+		err := c.store.Replace(base, blocks)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	c.base = base
+	c.setBlocks(blocks)
+	return true, nil
+}
+
+// returns work of blocks starting from index including
+// headers of base, lock must be held
+func (c *Chain) work(index int) *big.Int {
+	w := new(big.Int)
+	for i := index; i < c.first(); i++ {
+		w.Add(w, work(c.base.Headers[i].Complexity))
+	}
+	if index < c.first() {
+		index = c.first()
+	}
+	if i := index - c.first(); i < len(c.blocks) {
+		w.Add(w, blocksWork(c.blocks[i:]))
+	}
+	return w
+}
+
+// returns work of blocks
+func blocksWork(blocks []*Block) *big.Int {
+	w := new(big.Int)
+	for _, blk := range blocks {
+		w.Add(w, work(blk.Complexity))
+	}
+	return w
+}
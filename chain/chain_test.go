@@ -0,0 +1,157 @@
+package chain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+func TestBlockHash(t *testing.T) {
+	var (
+		data  interface{} = "aa"
+		short interface{} = "a"
+		blob              = "aa"
+	)
+	tests := []struct {
+		name string
+		// facts which boundaries between fields differ
+		a, b *chain.Fact
+	}{
+		{
+			name: "id and type",
+			a:    &chain.Fact{Id: "1test", Type: "/item", Fact: &data},
+			b:    &chain.Fact{Id: "1", Type: "test/item", Fact: &data},
+		},
+		{
+			name: "type and author",
+			a:    &chain.Fact{Id: "1", Type: "test/item", Author: "bob", Fact: &data},
+			b:    &chain.Fact{Id: "1", Type: "test/itemb", Author: "ob", Fact: &data},
+		},
+		{
+			name: "author and data",
+			a:    &chain.Fact{Id: "1", Author: "bob", Fact: &data},
+			b:    &chain.Fact{Id: "1", Author: "boba", Fact: &short},
+		},
+		{
+			name: "blob and data",
+			a:    &chain.Fact{Id: "1", Blob: blob},
+			b:    &chain.Fact{Id: "1", Fact: &data},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genesis := chain.Genesis()
+			a := &chain.Block{Index: 1, PrevHash: genesis.Hash, Timestamp: genesis.Timestamp, Facts: []*chain.Fact{tt.a}}
+			b := &chain.Block{Index: 1, PrevHash: genesis.Hash, Timestamp: genesis.Timestamp, Facts: []*chain.Fact{tt.b}}
+			if a.CalcHash() == b.CalcHash() {
+				t.Fatalf("want different hashes of %+v and %+v", tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestHeaderHash(t *testing.T) {
+	var data interface{} = "data"
+	blk := chain.NextBlock(chain.Genesis(), []*chain.Fact{{Id: "1", Fact: &data}, {Id: "2", Fact: &data}, {Id: "3", Fact: &data}}, chain.Difficulty{})
+	h := blk.Header()
+	if h.CalcHash() != blk.Hash {
+		t.Fatalf("want header hash %s, got %s", blk.Hash, h.CalcHash())
+	}
+
+	// facts are committed by their merkle root
+	blk.Facts = append(blk.Facts, blk.Facts[2])
+	if blk.CalcHash() == h.CalcHash() {
+		t.Fatalf("want hash changed by duplicated fact")
+	}
+}
+
+// returns solved block of complexity created after prev
+func after(prev *chain.Block, d time.Duration, complexity int) *chain.Block {
+	blk := &chain.Block{
+		Index:      prev.Index + 1,
+		PrevHash:   prev.Hash,
+		Timestamp:  prev.Timestamp.Add(d),
+		Complexity: complexity,
+	}
+	blk.Hash = blk.CalcHash()
+	chaintest.Solve(blk)
+	return blk
+}
+
+func TestIsValidNext(t *testing.T) {
+	d := chain.Difficulty{BlockInterval: time.Minute, MinComplexity: 1, MaxComplexity: 2}
+	genesis := chain.Genesis()
+	// complexity of genesis block is below min complexity
+	fast := after(genesis, time.Second, 1)
+	top := after(fast, time.Second, 2)
+
+	tests := []struct {
+		name  string
+		prev  *chain.Block
+		blk   *chain.Block
+		valid bool
+	}{
+		{name: "raised to min complexity", prev: genesis, blk: fast, valid: true},
+		{name: "increased", prev: fast, blk: after(fast, time.Second, 2), valid: true},
+		{name: "decreased", prev: fast, blk: after(fast, time.Hour, 1), valid: true},
+		{name: "not increased", prev: fast, blk: after(fast, time.Second, 1)},
+		{name: "not decreased", prev: fast, blk: after(fast, time.Hour, 2)},
+		{name: "stays at max complexity", prev: top, blk: after(top, time.Second, 2), valid: true},
+		{name: "above max complexity", prev: top, blk: after(top, time.Second, 3)},
+		{name: "dropped", prev: genesis, blk: after(genesis, time.Second, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if chain.IsValidNext(tt.prev, tt.blk, d) != tt.valid {
+				t.Fatalf("want valid %v of block of complexity %d after %d", tt.valid, tt.blk.Complexity, tt.prev.Complexity)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	d := chain.Difficulty{BlockInterval: time.Minute, MinComplexity: 0, MaxComplexity: 2}
+	genesis := chain.Genesis()
+	// blocks created fast have higher complexity, so
+	// 2 blocks have more work than 4 created slowly
+	b1 := after(genesis, time.Second, 1)
+	heavy := []*chain.Block{genesis, b1, after(b1, time.Second, 2)}
+	light := []*chain.Block{genesis}
+	for len(light) < 5 {
+		light = append(light, after(light[len(light)-1], time.Hour, 0))
+	}
+
+	tests := []struct {
+		name     string
+		blocks   []*chain.Block
+		received []*chain.Block
+		replaced bool
+		err      error
+	}{
+		{name: "more work", blocks: light, received: heavy, replaced: true},
+		{name: "longer with less work", blocks: heavy, received: light},
+		{name: "following blocks", blocks: heavy[:2], received: heavy[2:], replaced: true},
+		{name: "same work", blocks: heavy, received: heavy[1:]},
+		{
+			name:     "wrong complexity",
+			blocks:   light[:1],
+			received: []*chain.Block{genesis, after(genesis, time.Second, 2)},
+			err:      chain.ErrInvalidBlock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := chain.New(append([]*chain.Block(nil), tt.blocks...))
+			c.SetDifficulty(d)
+			replaced, err := c.Replace(tt.received)
+			if replaced != tt.replaced || err != tt.err {
+				t.Fatalf("want replaced %v and error %v, got %v and %v", tt.replaced, tt.err, replaced, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Package chaintest implements blockchain fixtures for tests.
+package chaintest
+
+import (
+	"strconv"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// Complexity is complexity of mined blocks, it is low
+// so blocks are solved in a few attempts
+const Complexity = 1
+
+// Difficulty keeps complexity of mined blocks
+var Difficulty = chain.Difficulty{MinComplexity: Complexity, MaxComplexity: Complexity}
+
+// Solve sets nonce that solves block
+func Solve(blk *chain.Block) {
+	for i := 0; !blk.Solved(); i++ {
+		blk.Nonce = strconv.Itoa(i)
+	}
+}
+
+// Unsolve sets nonce that doesn't solve block
+func Unsolve(blk *chain.Block) {
+	for i := 0; blk.Solved(); i++ {
+		blk.Nonce = strconv.Itoa(i)
+	}
+}
+
+// Mine returns solved block with facts following prev
+func Mine(prev *chain.Block, facts ...*chain.Fact) *chain.Block {
+	blk := chain.NextBlock(prev, facts, Difficulty)
+	Solve(blk)
+	return blk
+}
+
+// NewChain returns valid blockchain of count blocks, each
+// block after genesis has fact with id of block index
+func NewChain(count int) []*chain.Block {
+	blocks := []*chain.Block{chain.Genesis()}
+	for len(blocks) < count {
+		blocks = append(blocks, Mine(blocks[len(blocks)-1], &chain.Fact{Id: strconv.Itoa(len(blocks))}))
+	}
+	return blocks
+}
@@ -0,0 +1,34 @@
+package chain
+
+// FactHash returns hash of fact, leaf of merkle tree of block facts
+func FactHash(fact *Fact) string {
+	return CalcHash(factString(fact))
+}
+
+// MerkleRoot returns root of merkle tree of facts hashes, hash
+// without pair is moved to the next level as is, so facts can't be
+// duplicated without changing the root. Root is a part of block hash
+// and is kept in block header, so fact can be checked against block
+// after its facts are pruned. Empty for block without facts
+func MerkleRoot(facts []*Fact) string {
+	if len(facts) == 0 {
+		return ""
+	}
+
+	level := make([]string, len(facts))
+	for i, fact := range facts {
+		level[i] = FactHash(fact)
+	}
+	for len(level) > 1 {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, CalcHash(level[i]+level[i+1]))
+		}
+		level = next
+	}
+	return level[0]
+}
@@ -0,0 +1,65 @@
+package chain
+
+import "testing"
+
+func TestMerkleRoot(t *testing.T) {
+	var (
+		data       interface{} = map[string]interface{}{"a": 1.0}
+		a                      = &Fact{Id: "a", Fact: &data}
+		b                      = &Fact{Id: "b", Type: "test/item", Author: "bob", Fact: &data}
+		c                      = &Fact{Id: "c", Blob: CalcHash("blob")}
+		ha, hb, hc             = FactHash(a), FactHash(b), FactHash(c)
+	)
+	tests := []struct {
+		name  string
+		facts []*Fact
+		root  string
+	}{
+		{name: "no facts", root: ""},
+		// single leaf is the root
+		{name: "one fact", facts: []*Fact{a}, root: ha},
+		{name: "two facts", facts: []*Fact{a, b}, root: CalcHash(ha + hb)},
+		// hash without pair is moved up as is
+		{name: "odd count", facts: []*Fact{a, b, c}, root: CalcHash(CalcHash(ha+hb) + hc)},
+		{name: "duplicated odd fact", facts: []*Fact{a, b, c, c}, root: CalcHash(CalcHash(ha+hb) + CalcHash(hc+hc))},
+		{name: "order matters", facts: []*Fact{b, a}, root: CalcHash(hb + ha)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := MerkleRoot(tt.facts)
+			if root != tt.root {
+				t.Fatalf("want %s, got %s", tt.root, root)
+			}
+		})
+	}
+}
+
+func TestFactHash(t *testing.T) {
+	var data interface{} = "data"
+	fact := &Fact{Id: "1", Type: "test/item", Author: "bob", Fact: &data}
+	hash := FactHash(fact)
+
+	var other interface{} = "other"
+	tests := []struct {
+		name string
+		fact *Fact
+	}{
+		{name: "id", fact: &Fact{Id: "2", Type: "test/item", Author: "bob", Fact: &data}},
+		{name: "type", fact: &Fact{Id: "1", Type: "test/other", Author: "bob", Fact: &data}},
+		{name: "id and type boundary", fact: &Fact{Id: "1test", Type: "/item", Author: "bob", Fact: &data}},
+		{name: "type and author boundary", fact: &Fact{Id: "1", Type: "test/itemb", Author: "ob", Fact: &data}},
+		{name: "author", fact: &Fact{Id: "1", Type: "test/item", Author: "alice", Fact: &data}},
+		{name: "data", fact: &Fact{Id: "1", Type: "test/item", Author: "bob", Fact: &other}},
+		{name: "blob", fact: &Fact{Id: "1", Type: "test/item", Author: "bob", Blob: CalcHash("data")}},
+		{name: "without data", fact: &Fact{Id: "1", Type: "test/item", Author: "bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if FactHash(tt.fact) == hash {
+				t.Fatalf("want hash changed by %s", tt.name)
+			}
+		})
+	}
+}
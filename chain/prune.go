@@ -0,0 +1,79 @@
+package chain
+
+import "encoding/json"
+
+// First returns index of the first block kept with facts,
+// earlier blocks are pruned or received with snapshot
+func (c *Chain) First() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.first()
+}
+
+// Prune moves blocks older than the latest keep blocks or not fitting
+// in maxBytes of block json to base, their headers and fact index are
+// kept, but facts are discarded. Zero limit means no limit, the latest
+// block is always kept. Blocks are pruned only if at least batch of them
+// are old, so store doesn't rewrite its blocks on each new block.
+// Returns count of pruned blocks
+func (c *Chain) Prune(keep, maxBytes, batch int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cut := 0
+	if keep > 0 && len(c.blocks) > keep {
+		cut = len(c.blocks) - keep
+	}
+	if maxBytes > 0 {
+		size := 0
+		for i := len(c.blocks) - 1; i >= cut; i-- {
+			size += Size(c.blocks[i])
+			if size > maxBytes {
+				cut = i + 1
+				break
+			}
+		}
+	}
+	if cut > len(c.blocks)-1 {
+		cut = len(c.blocks) - 1
+	}
+	if cut <= 0 || cut < batch {
+		return 0, nil
+	}
+
+	base := &Base{Facts: make(map[string]int)}
+	if c.base != nil {
+		base.Headers = append(base.Headers, c.base.Headers...)
+	}
+	for _, blk := range c.blocks[:cut] {
+		base.Headers = append(base.Headers, blk.Header())
+	}
+	last := c.blocks[cut-1].Index
+	for id, i := range c.byFact {
+		if i <= last {
+			base.Facts[id] = i
+		}
+	}
+
+	blocks := append([]*Block(nil), c.blocks[cut:]...)
+	if c.store != nil {
+		err := c.store.Replace(base, blocks)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	c.base = base
+	c.setBlocks(blocks)
+	return cut, nil
+}
+
+// Size returns size of block json
+func Size(blk *Block) int {
+	data, err := json.Marshal(blk)
+	if err != nil {
+		panic(err)
+	}
+	return len(data)
+}
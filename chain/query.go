@@ -0,0 +1,190 @@
+package chain
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// FactRef type for store position of confirmed fact
+type FactRef struct {
+	// block index
+	Block int
+	// fact index in block
+	Pos int
+}
+
+// less reports whether ref is before other in blockchain
+func (r FactRef) less(other FactRef) bool {
+	return r.Block < other.Block || r.Block == other.Block && r.Pos < other.Pos
+}
+
+// Query type for filter confirmed facts,
+// zero value fields are not used
+type Query struct {
+	Type   string
+	Author string
+	// block timestamp range, until is exclusive
+	Since time.Time
+	Until time.Time
+	// top level fields values of object facts,
+	// values are compared as text
+	Fields map[string]string
+	// facts are returned after this position
+	After *FactRef
+	// max count of facts, all facts if zero
+	Limit int
+}
+
+// FoundFact type for send confirmed fact with its block
+type FoundFact struct {
+	Fact  *Fact
+	Block *Block
+	Ref   FactRef
+}
+
+// returns key of fact field value in fields index
+// and false if field value is not indexed
+func fieldKey(name string, v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return name + "\x00" + v, true
+	case map[string]interface{}, []interface{}:
+		return "", false
+	}
+	// numbers, booleans and null as json text
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return name + "\x00" + string(data), true
+}
+
+// add block facts to query indexes, lock must be held
+func (c *Chain) indexFacts(blk *Block) {
+	for i, fact := range blk.Facts {
+		ref := FactRef{Block: blk.Index, Pos: i}
+		if fact.Type != "" {
+			c.byType[fact.Type] = append(c.byType[fact.Type], ref)
+		}
+		if fact.Author != "" {
+			c.byAuthor[fact.Author] = append(c.byAuthor[fact.Author], ref)
+		}
+
+		if fact.Fact == nil {
+			continue
+		}
+		obj, ok := (*fact.Fact).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name, v := range obj {
+			if key, ok := fieldKey(name, v); ok {
+				c.byField[key] = append(c.byField[key], ref)
+			}
+		}
+	}
+}
+
+// Query returns confirmed facts matching query in blockchain order
+func (c *Chain) Query(q Query) []*FoundFact {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// the shortest index list of query filters,
+	// all facts are scanned if query has no one
+	var (
+		refs    []FactRef
+		indexed bool
+	)
+	use := func(list []FactRef) {
+		if !indexed || len(list) < len(refs) {
+			refs, indexed = list, true
+		}
+	}
+	if q.Type != "" {
+		use(c.byType[q.Type])
+	}
+	if q.Author != "" {
+		use(c.byAuthor[q.Author])
+	}
+	for name, v := range q.Fields {
+		use(c.byField[name+"\x00"+v])
+	}
+
+	var found []*FoundFact
+	add := func(ref FactRef) bool {
+		if q.Limit > 0 && len(found) == q.Limit {
+			return false
+		}
+		blk, _ := c.get(ref.Block)
+		if q.matches(blk, blk.Facts[ref.Pos]) {
+			found = append(found, &FoundFact{Fact: blk.Facts[ref.Pos], Block: blk, Ref: ref})
+		}
+		return true
+	}
+
+	if indexed {
+		i := 0
+		if q.After != nil {
+			i = sort.Search(len(refs), func(i int) bool { return q.After.less(refs[i]) })
+		}
+		for _, ref := range refs[i:] {
+			if !add(ref) {
+				break
+			}
+		}
+		return found
+	}
+
+	// facts of blocks before the first kept block are unknown
+	start := FactRef{Block: c.first()}
+	if q.After != nil && q.After.Block >= start.Block {
+		start = FactRef{Block: q.After.Block, Pos: q.After.Pos + 1}
+	}
+	for b := start.Block; b <= c.height(); b++ {
+		pos := 0
+		if b == start.Block {
+			pos = start.Pos
+		}
+		blk, _ := c.get(b)
+		for ; pos < len(blk.Facts); pos++ {
+			if !add(FactRef{Block: b, Pos: pos}) {
+				return found
+			}
+		}
+	}
+	return found
+}
+
+// check that fact of block matches query
+func (q *Query) matches(blk *Block, fact *Fact) bool {
+	if q.Type != "" && fact.Type != q.Type ||
+		q.Author != "" && fact.Author != q.Author ||
+		!q.Since.IsZero() && blk.Timestamp.Before(q.Since) ||
+		!q.Until.IsZero() && !blk.Timestamp.Before(q.Until) {
+		return false
+	}
+	if len(q.Fields) == 0 {
+		return true
+	}
+
+	if fact.Fact == nil {
+		return false
+	}
+	obj, ok := (*fact.Fact).(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for name, want := range q.Fields {
+		v, ok := obj[name]
+		if !ok {
+			return false
+		}
+		key, ok := fieldKey(name, v)
+		if !ok || key != name+"\x00"+want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package chain
+
+import (
+	"fmt"
+	"time"
+)
+
+// kinds of blockchain problems
+const (
+	// BROKEN_LINK means that block doesn't follow previous block
+	BROKEN_LINK = "broken_link"
+	// BAD_HASH means that block hash doesn't match block data
+	BAD_HASH = "bad_hash"
+	// INSUFFICIENT_POW means that block nonce doesn't solve block
+	INSUFFICIENT_POW = "insufficient_pow"
+	// BAD_COMPLEXITY means that block complexity
+	// doesn't follow difficulty from previous block
+	BAD_COMPLEXITY = "bad_complexity"
+	// DUPLICATE_FACT means that fact id is already used
+	DUPLICATE_FACT = "duplicate_fact"
+	// TIMESTAMP means that block is created before
+	// previous block or in the future
+	TIMESTAMP = "timestamp"
+)
+
+// MaxFutureDrift is default time allowed for block timestamps after now
+const MaxFutureDrift = 2 * time.Hour
+
+// Problem type for send invalid block found by verification
+type Problem struct {
+	// block index in the blockchain
+	Index   int    `json:"index"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// VerifyOptions type for store verification rules
+type VerifyOptions struct {
+	// time verification is made at, current time if zero
+	Now time.Time
+	// time allowed for block timestamps after now
+	MaxFutureDrift time.Duration
+	// skip proof of work of blocks without nonce,
+	// nonce was not kept by older nodes
+	AllowEmptyNonce bool
+	// complexity rules of the node blocks are created by
+	Difficulty Difficulty
+}
+
+// Verify checks all blocks and returns all found problems,
+// unlike IsValidChain it doesn't stop on the first invalid block.
+// Blocks and facts are not signed, so there are no signatures to check
+func Verify(blocks []*Block, opts VerifyOptions) []*Problem {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	var (
+		problems = []*Problem{}
+		// fact id -> block index
+		facts = make(map[string]int)
+	)
+	report := func(i int, kind, format string, a ...interface{}) {
+		problems = append(problems, &Problem{Index: i, Kind: kind, Message: fmt.Sprintf(format, a...)})
+	}
+	if len(blocks) == 0 {
+		report(0, BROKEN_LINK, "no genesis block")
+		return problems
+	}
+
+	for i, blk := range blocks {
+		if blk.Index != i {
+			report(i, BROKEN_LINK, "block index is %d, want %d", blk.Index, i)
+		}
+		if i == 0 && blk.PrevHash != "" {
+			report(i, BROKEN_LINK, "genesis block has previous hash %q", blk.PrevHash)
+		}
+		if i > 0 && blk.PrevHash != blocks[i-1].Hash {
+			report(i, BROKEN_LINK, "previous hash %q doesn't match hash of block %d", blk.PrevHash, i-1)
+		}
+
+		if blk.CalcHash() != blk.Hash {
+			report(i, BAD_HASH, "hash %q doesn't match block data", blk.Hash)
+		}
+		// genesis block is not mined
+		if i > 0 && !(blk.Nonce == "" && opts.AllowEmptyNonce) && !blk.Solved() {
+			report(i, INSUFFICIENT_POW, "nonce %q doesn't solve block of complexity %d", blk.Nonce, blk.Complexity)
+		}
+		if i > 0 {
+			prev := blocks[i-1]
+			want := opts.Difficulty.Next(prev.Complexity, blk.Timestamp.Sub(prev.Timestamp))
+			if blk.Complexity != want {
+				report(i, BAD_COMPLEXITY, "complexity is %d, want %d after block %d of complexity %d", blk.Complexity, want, i-1, prev.Complexity)
+			}
+		}
+
+		if i > 0 && blk.Timestamp.Before(blocks[i-1].Timestamp) {
+			report(i, TIMESTAMP, "block is created before block %d", i-1)
+		}
+		if blk.Timestamp.After(opts.Now.Add(opts.MaxFutureDrift)) {
+			report(i, TIMESTAMP, "block is created in the future at %s", blk.Timestamp.Format(time.RFC3339))
+		}
+
+		for _, fact := range blk.Facts {
+			if first, ok := facts[fact.Id]; ok {
+				report(i, DUPLICATE_FACT, "fact %s is already in block %d", fact.Id, first)
+				continue
+			}
+			facts[fact.Id] = i
+		}
+	}
+	return problems
+}
+
+// Invalid returns kind of the first problem of blocks which should
+// follow each other, like BROKEN_LINK, empty if they are valid
+func Invalid(blocks []*Block, d Difficulty) string {
+	for i, blk := range blocks {
+		if i > 0 && (blk.Index != blocks[i-1].Index+1 || blk.PrevHash != blocks[i-1].Hash) {
+			return BROKEN_LINK
+		}
+		if blk.CalcHash() != blk.Hash {
+			return BAD_HASH
+		}
+		// genesis block is not mined
+		if blk.Index > 0 && !blk.Solved() {
+			return INSUFFICIENT_POW
+		}
+		if i > 0 && blk.Complexity != d.Next(blocks[i-1].Complexity, blk.Timestamp.Sub(blocks[i-1].Timestamp)) {
+			return BAD_COMPLEXITY
+		}
+	}
+	return ""
+}
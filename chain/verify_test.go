@@ -0,0 +1,217 @@
+package chain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+// sets block timestamp and recalculates its hash
+func retime(blk *chain.Block, t time.Time) {
+	blk.Timestamp = t
+	blk.Hash = blk.CalcHash()
+}
+
+// sets block complexity and recalculates its hash
+func recomplex(blk *chain.Block, complexity int) {
+	blk.Complexity = complexity
+	blk.Hash = blk.CalcHash()
+	chaintest.Solve(blk)
+}
+
+// clears block nonce, timestamp of block is changed
+// until block isn't solved without nonce
+func clearNonce(blk *chain.Block) {
+	blk.Nonce = ""
+	for blk.Solved() {
+		retime(blk, blk.Timestamp.Add(time.Nanosecond))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name   string
+		opts   chain.VerifyOptions
+		change func(blocks []*chain.Block) []*chain.Block
+		// kinds of problems found in order
+		kinds []string
+	}{
+		{name: "valid", change: func(b []*chain.Block) []*chain.Block { return b }},
+		{
+			name:   "empty",
+			change: func(b []*chain.Block) []*chain.Block { return nil },
+			kinds:  []string{chain.BROKEN_LINK},
+		},
+		{
+			name: "genesis with previous hash",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[0].PrevHash = b[1].Hash
+				return b
+			},
+			// genesis hash changes with previous hash
+			kinds: []string{chain.BROKEN_LINK, chain.BAD_HASH},
+		},
+		{
+			name:   "missing block",
+			change: func(b []*chain.Block) []*chain.Block { return append(b[:1], b[2:]...) },
+			// each next block has wrong index
+			kinds: []string{chain.BROKEN_LINK, chain.BROKEN_LINK, chain.BROKEN_LINK, chain.BROKEN_LINK},
+		},
+		{
+			name: "bad hash",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Hash = chain.CalcHash("changed")
+				return b
+			},
+			kinds: []string{chain.BAD_HASH, chain.BROKEN_LINK},
+		},
+		{
+			name: "unsolved block",
+			change: func(b []*chain.Block) []*chain.Block {
+				chaintest.Unsolve(b[3])
+				return b
+			},
+			kinds: []string{chain.INSUFFICIENT_POW},
+		},
+		{
+			name: "empty nonce",
+			change: func(b []*chain.Block) []*chain.Block {
+				clearNonce(b[3])
+				return b[:4]
+			},
+			kinds: []string{chain.INSUFFICIENT_POW},
+		},
+		{
+			name: "empty nonce allowed",
+			opts: chain.VerifyOptions{AllowEmptyNonce: true},
+			change: func(b []*chain.Block) []*chain.Block {
+				clearNonce(b[3])
+				return b[:4]
+			},
+		},
+		{
+			name: "complexity dropped",
+			change: func(b []*chain.Block) []*chain.Block {
+				recomplex(b[3], 0)
+				return b[:4]
+			},
+			kinds: []string{chain.BAD_COMPLEXITY},
+		},
+		{
+			name: "complexity raised",
+			change: func(b []*chain.Block) []*chain.Block {
+				recomplex(b[3], 2)
+				return b[:4]
+			},
+			kinds: []string{chain.BAD_COMPLEXITY},
+		},
+		{
+			name: "duplicate fact",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[3] = chaintest.Mine(b[2], &chain.Fact{Id: "1"})
+				return b[:4]
+			},
+			kinds: []string{chain.DUPLICATE_FACT},
+		},
+		{
+			name: "timestamp before previous block",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[3] = chaintest.Mine(b[2])
+				retime(b[3], b[2].Timestamp.Add(-time.Second))
+				chaintest.Solve(b[3])
+				return b[:4]
+			},
+			kinds: []string{chain.TIMESTAMP},
+		},
+		{
+			name: "timestamp in the future",
+			opts: chain.VerifyOptions{Now: now, MaxFutureDrift: time.Hour},
+			change: func(b []*chain.Block) []*chain.Block {
+				b[3] = chaintest.Mine(b[2])
+				retime(b[3], now.Add(2*time.Hour))
+				chaintest.Solve(b[3])
+				return b[:4]
+			},
+			kinds: []string{chain.TIMESTAMP},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Difficulty = chaintest.Difficulty
+			problems := chain.Verify(tt.change(chaintest.NewChain(5)), tt.opts)
+
+			var kinds []string
+			for _, p := range problems {
+				kinds = append(kinds, p.Kind)
+			}
+			if len(kinds) != len(tt.kinds) {
+				t.Fatalf("want problems %v, got %v", tt.kinds, kinds)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.kinds[i] {
+					t.Fatalf("want problems %v, got %v", tt.kinds, kinds)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(blocks []*chain.Block) []*chain.Block
+		kind   string
+	}{
+		{name: "valid", change: func(b []*chain.Block) []*chain.Block { return b }},
+		{name: "part of blockchain", change: func(b []*chain.Block) []*chain.Block { return b[2:] }},
+		{name: "missing block", change: func(b []*chain.Block) []*chain.Block { return append(b[:1], b[2:]...) }, kind: chain.BROKEN_LINK},
+		{
+			name: "other previous hash",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2] = chaintest.Mine(b[0])
+				b[2].Index = 2
+				b[2].Hash = b[2].CalcHash()
+				chaintest.Solve(b[2])
+				return b[:3]
+			},
+			kind: chain.BROKEN_LINK,
+		},
+		{
+			name: "bad hash",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Hash = chain.CalcHash("changed")
+				return b[:3]
+			},
+			kind: chain.BAD_HASH,
+		},
+		{
+			name: "unsolved block",
+			change: func(b []*chain.Block) []*chain.Block {
+				chaintest.Unsolve(b[2])
+				return b
+			},
+			kind: chain.INSUFFICIENT_POW,
+		},
+		{
+			name: "complexity dropped",
+			change: func(b []*chain.Block) []*chain.Block {
+				recomplex(b[2], 0)
+				return b[:3]
+			},
+			kind: chain.BAD_COMPLEXITY,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := chain.Invalid(tt.change(chaintest.NewChain(4)), chaintest.Difficulty)
+			if kind != tt.kind {
+				t.Fatalf("want %q, got %q", tt.kind, kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/config"
+	"github.com/lavrs/blkchn/store"
+)
+
+// show block by index or hash
+func blockGet(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	var blk *chain.Block
+	if index, err := strconv.Atoi(fs.Arg(0)); err == nil {
+		blk, err = c.GetBlock(ctx, index)
+	} else {
+		blk, err = c.GetBlockByHash(ctx, fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	if e.format == formatJSON {
+		return e.printJSON(blk)
+	}
+	err = e.printTable([][]string{
+		{"INDEX", strconv.Itoa(blk.Index)},
+		{"HASH", blk.Hash},
+		{"PREV HASH", blk.PrevHash},
+		{"TIMESTAMP", blk.Timestamp.Format(time.RFC3339)},
+		{"COMPLEXITY", strconv.Itoa(blk.Complexity)},
+		{"NONCE", blk.Nonce},
+		{"FACTS", strconv.Itoa(len(blk.Facts))},
+	})
+	if err != nil || len(blk.Facts) == 0 {
+		return err
+	}
+
+	rows := [][]string{{""}, {"ID", "TYPE", "AUTHOR", "FACT"}}
+	for _, fact := range blk.Facts {
+		rows = append(rows, []string{fact.Id, fact.Type, fact.Author, factString(fact)})
+	}
+	return e.printTable(rows)
+}
+
+// adds flags of complexity rules blocks are checked by,
+// defaults are the same as defaults of the node
+func difficultyFlags(fs *flag.FlagSet) *chain.Difficulty {
+	def := config.Default().Difficulty
+	d := &chain.Difficulty{}
+	fs.DurationVar(&d.BlockInterval, "block-interval", def.BlockInterval.Duration, "difficulty block interval of the node")
+	fs.IntVar(&d.MinComplexity, "min-complexity", def.MinComplexity, "difficulty min complexity of the node")
+	fs.IntVar(&d.MaxComplexity, "max-complexity", def.MaxComplexity, "difficulty max complexity of the node")
+	return d
+}
+
+// verifyResult type for send blockchain check result
+type verifyResult struct {
+	Valid bool `json:"valid"`
+	// latest block index
+	Height   int              `json:"height"`
+	Problems []*chain.Problem `json:"problems"`
+}
+
+// check blocks of the node or of the dump file
+func chainVerify(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	var opts chain.VerifyOptions
+	file := fs.String("file", "", "check blockchain dump file instead of node, - means stdin")
+	dataDir := fs.String("d", "", "check blocks stored in node data directory instead of node")
+	fs.DurationVar(&opts.MaxFutureDrift, "max-drift", chain.MaxFutureDrift, "time allowed for block timestamps after now")
+	fs.BoolVar(&opts.AllowEmptyNonce, "allow-empty-nonce", false, "skip proof of work of blocks without nonce")
+	d := difficultyFlags(fs)
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	opts.Difficulty = *d
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var blocks []*chain.Block
+	switch {
+	case *file != "":
+		blocks, err = readDump(*file)
+	case *dataDir != "":
+		blocks, err = readDump(store.Path(*dataDir))
+	default:
+		blocks, err = e.download(ctx)
+	}
+	if err == nil {
+		err = checkFull(blocks)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := &verifyResult{Height: len(blocks) - 1, Problems: chain.Verify(blocks, opts)}
+	res.Valid = len(res.Problems) == 0
+
+	if e.format == formatJSON {
+		err = e.printJSON(res)
+	} else {
+		rows := [][]string{{"BLOCK", "PROBLEM", "MESSAGE"}}
+		for _, p := range res.Problems {
+			rows = append(rows, []string{strconv.Itoa(p.Index), p.Kind, p.Message})
+		}
+		if res.Valid {
+			rows = [][]string{{"VALID", "HEIGHT"}, {"true", strconv.Itoa(res.Height)}}
+		}
+		err = e.printTable(rows)
+	}
+	if err != nil {
+		return err
+	}
+	if !res.Valid {
+		return fmt.Errorf("blockchain is invalid, %d problems found", len(res.Problems))
+	}
+	return nil
+}
+
+// check that blocks start from genesis block, node started
+// from snapshot doesn't have blocks before it
+func checkFull(blocks []*chain.Block) error {
+	if len(blocks) != 0 && blocks[0].Index != 0 {
+		return fmt.Errorf("blocks start from block %d, node is started from snapshot", blocks[0].Index)
+	}
+	return nil
+}
+
+// returns all blocks of the node
+func (e *env) download(ctx context.Context) ([]*chain.Block, error) {
+	c, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+	// pages are requested until the last one,
+	// blocks appended meanwhile are checked too
+	t, err := c.GetBlocks(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	blocks := t.Blocks
+	for t.Next != "" {
+		t, err = c.GetBlocksAfter(ctx, t.Next, 0)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, t.Blocks...)
+	}
+	return blocks, nil
+}
+
+// returns blocks of dump file: block file, response
+// of /blockchain, page of /v1/blocks or array of blocks
+func readDump(path string) ([]*chain.Block, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := store.NewReader(bytes.NewReader(data))
+	if err == nil {
+		var blocks []*chain.Block
+		for {
+			blk, err := r.Read()
+			if err == io.EOF {
+				return blocks, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: block %d: %v", path, len(blocks), err)
+			}
+			blocks = append(blocks, blk)
+		}
+	}
+	if err != store.ErrFormat {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var blocks []*chain.Block
+		err = json.Unmarshal(data, &blocks)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return blocks, nil
+	}
+
+	var t struct {
+		Blockchain []*chain.Block `json:"blockchain"`
+		Blocks     []*chain.Block `json:"blocks"`
+	}
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if t.Blockchain != nil {
+		return t.Blockchain, nil
+	}
+	return t.Blocks, nil
+}
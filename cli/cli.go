@@ -0,0 +1,263 @@
+// Package cli implements blkchn command line interface:
+// running node and operating running node over its http api.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/lavrs/blkchn/client"
+)
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// output formats
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+const (
+	// environment variables prefix
+	envPrefix = "BLKCHN_"
+	// node http api address by default
+	defaultNode = "localhost:1000"
+)
+
+// errUsage means that command is called with invalid arguments,
+// usage is already printed
+var errUsage = errors.New("usage")
+
+// env type for store command streams and common flags
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+	// node http api address
+	node string
+	// output format
+	format string
+}
+
+// command type for store subcommand
+type command struct {
+	// arguments description for usage
+	args  string
+	short string
+	run   func(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error
+}
+
+// subcommands by name, nested ones are separated by space
+var commands = map[string]*command{
+	"node run": {
+		args:  "[node flags]",
+		short: "run node, the default command",
+	},
+	"fact submit": {
+		args:  "file.json",
+		short: "submit fact read from file, - means stdin",
+		run:   factSubmit,
+	},
+	"fact get": {
+		args:  "<id>",
+		short: "show fact and its status",
+		run:   factGet,
+	},
+	"blob get": {
+		args:  "<hash>",
+		short: "write fact data kept off-chain to stdout",
+		run:   blobGet,
+	},
+	"block get": {
+		args:  "<index|hash>",
+		short: "show block",
+		run:   blockGet,
+	},
+	"chain export": {
+		args:  "file.blk",
+		short: "write blocks of the node or of the data dir to file, - means stdout",
+		run:   chainExport,
+	},
+	"chain import": {
+		args:  "file.blk",
+		short: "check blocks of file and write them to data dir, - means stdin",
+		run:   chainImport,
+	},
+	"chain verify": {
+		short: "check blocks of the node, of the dump file or of the data dir",
+		run:   chainVerify,
+	},
+	"peers": {
+		short: "show connected nodes",
+		run:   peers,
+	},
+	"mine": {
+		short: "mine blocks of the node",
+		run:   mine,
+	},
+}
+
+// Run runs command with arguments without program name
+// and returns exit code. Node is run if there is no command
+// to keep node flags working without it
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runNode(args, stderr)
+	}
+
+	name, cmd, rest := lookup(args)
+	if cmd == nil {
+		if args[0] == "help" {
+			usage(stdout)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "unknown command %q\n", strings.Join(args, " "))
+		usage(stderr)
+		return exitUsage
+	}
+	if cmd.run == nil {
+		return runNode(rest, stderr)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	// command defines its flags and parses them
+	e := &env{stdout: stdout, stderr: stderr}
+	err := cmd.run(ctx, e, e.flags(name, cmd), rest)
+	switch {
+	case err == errUsage:
+		return exitUsage
+	case err != nil:
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+// returns the longest command matching the first arguments
+func lookup(args []string) (string, *command, []string) {
+	if len(args) > 1 {
+		name := args[0] + " " + args[1]
+		if cmd, ok := commands[name]; ok {
+			return name, cmd, args[2:]
+		}
+	}
+	if cmd, ok := commands[args[0]]; ok {
+		return args[0], cmd, args[1:]
+	}
+	return "", nil, nil
+}
+
+// print commands
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: blkchn <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", name, commands[name].args, commands[name].short)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands except node run accept flags:")
+	fmt.Fprintln(w, "  -node string")
+	fmt.Fprintf(w, "    \tnode http api address, %sNODE by default (default %q)\n", envPrefix, defaultNode)
+	fmt.Fprintln(w, "  -o string")
+	fmt.Fprintf(w, "    \toutput format: %s or %s (default %q)\n", formatTable, formatJSON, formatTable)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'blkchn node run -help' for node flags.")
+}
+
+// returns flag set of command with common flags
+func (e *env) flags(name string, cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(e.stderr, strings.TrimSpace("Usage: blkchn "+name+" [flags] "+cmd.args))
+		fs.PrintDefaults()
+	}
+
+	node := os.Getenv(envPrefix + "NODE")
+	if node == "" {
+		node = defaultNode
+	}
+	fs.StringVar(&e.node, "node", node, "node http api address")
+	fs.StringVar(&e.format, "o", formatTable, "output format: table or json")
+	return fs
+}
+
+// parse command flags and check common ones
+func (e *env) parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return errUsage
+	}
+
+	if e.format != formatTable && e.format != formatJSON {
+		fmt.Fprintf(e.stderr, "invalid value %q for flag -o\n", e.format)
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// returns client of the node
+func (e *env) client() (*client.Client, error) {
+	return client.New(e.node)
+}
+
+// print v as json
+func (e *env) printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.stdout, "%s\n", data)
+	return err
+}
+
+// print rows separated by tabs as aligned table
+func (e *env) printTable(rows [][]string) error {
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// returns context cancelled on interrupt
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sig)
+	}()
+	return ctx, cancel
+}
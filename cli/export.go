@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/lavrs/blkchn/store"
+)
+
+// transferResult type for send count of exported or imported blocks
+type transferResult struct {
+	Blocks int `json:"blocks"`
+	// latest block index
+	Height int `json:"height"`
+}
+
+// write blocks of the node or of the data dir to block file
+func chainExport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	dataDir := fs.String("d", "", "export blocks stored in node data directory instead of node")
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var w io.Writer = e.stdout
+	path := fs.Arg(0)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := e.export(ctx, w, *dataDir)
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		return err
+	}
+	if path == "-" {
+		// stdout is taken by blocks
+		return nil
+	}
+	return e.printTransfer(n)
+}
+
+// write blocks to w, returns count of written blocks
+func (e *env) export(ctx context.Context, w io.Writer, dataDir string) (int, error) {
+	bw, err := store.NewWriter(w)
+	if err != nil {
+		return 0, err
+	}
+
+	if dataDir != "" {
+		blocks, err := store.Load(dataDir)
+		if err == nil {
+			err = checkFull(blocks)
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, blk := range blocks {
+			err = bw.Write(blk)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(blocks), bw.Flush()
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return 0, err
+	}
+	// pages are written as soon as they are received
+	n := 0
+	t, err := c.GetBlocks(ctx, 0, 0)
+	if err == nil {
+		err = checkFull(t.Blocks)
+	}
+	for {
+		if err != nil {
+			return 0, err
+		}
+		for _, blk := range t.Blocks {
+			err = bw.Write(blk)
+			if err != nil {
+				return 0, err
+			}
+		}
+		n += len(t.Blocks)
+		if t.Next == "" {
+			return n, bw.Flush()
+		}
+		t, err = c.GetBlocksAfter(ctx, t.Next, 0)
+	}
+}
+
+// check blocks of block file and write them to data dir
+func chainImport(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	dataDir := fs.String("d", "", "node data directory, node must be stopped")
+	replace := fs.Bool("replace", false, "replace blocks already stored in data directory")
+	d := difficultyFlags(fs)
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *dataDir == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	n, err := store.Import(*dataDir, r, *d, *replace)
+	if err == store.ErrNotEmpty {
+		return fmt.Errorf("%v, use -replace to replace them", err)
+	}
+	if err != nil {
+		return err
+	}
+	return e.printTransfer(n)
+}
+
+// print count of exported or imported blocks
+func (e *env) printTransfer(n int) error {
+	t := &transferResult{Blocks: n, Height: n - 1}
+	if e.format == formatJSON {
+		return e.printJSON(t)
+	}
+	return e.printTable([][]string{{"BLOCKS", "HEIGHT"}, {strconv.Itoa(t.Blocks), strconv.Itoa(t.Height)}})
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/client"
+)
+
+// submit fact read from file
+func factSubmit(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	var opts client.FactOptions
+	fs.StringVar(&opts.Type, "type", "", "registered fact type")
+	fs.StringVar(&opts.Author, "author", "", "fact author")
+	offChain := fs.Bool("blob", false, "keep file off-chain in blob store, fact has only its hash")
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var data []byte
+	if fs.Arg(0) == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	if *offChain {
+		id, hash, err := c.SubmitBlob(ctx, data, opts)
+		if err != nil {
+			return err
+		}
+
+		if e.format == formatJSON {
+			return e.printJSON(map[string]string{"id": id, "blob": hash})
+		}
+		return e.printTable([][]string{{"ID", "BLOB"}, {id, hash}})
+	}
+
+	// fact is sent as is, numbers are kept
+	var fact json.RawMessage
+	err = json.Unmarshal(data, &fact)
+	if err != nil {
+		return err
+	}
+	id, err := c.SubmitFact(ctx, fact, opts)
+	if err != nil {
+		return err
+	}
+
+	if e.format == formatJSON {
+		return e.printJSON(map[string]string{"id": id})
+	}
+	return e.printTable([][]string{{"ID"}, {id}})
+}
+
+// show fact and its status
+func factGet(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	t, err := c.GetFactStatus(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if e.format == formatJSON {
+		return e.printJSON(t)
+	}
+	rows := [][]string{
+		{"ID", t.Fact.Id},
+		{"TYPE", t.Fact.Type},
+		{"AUTHOR", t.Fact.Author},
+		{"STATUS", t.Status},
+	}
+	if t.BlockIndex != nil {
+		rows = append(rows,
+			[]string{"BLOCK", strconv.Itoa(*t.BlockIndex)},
+			[]string{"CONFIRMATIONS", strconv.Itoa(t.Confirmations)})
+	}
+	if t.Fact.Blob != "" {
+		rows = append(rows, []string{"BLOB", t.Fact.Blob})
+	}
+	rows = append(rows, []string{"FACT", factString(t.Fact)})
+	return e.printTable(rows)
+}
+
+// write fact data kept off-chain to stdout
+func blobGet(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	data, err := c.GetBlob(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = e.stdout.Write(data)
+	return err
+}
+
+// returns fact data as compact json
+func factString(fact *chain.Fact) string {
+	if fact.Fact == nil {
+		return ""
+	}
+	data, err := json.Marshal(fact.Fact)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
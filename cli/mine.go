@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/client"
+	"github.com/lavrs/blkchn/events"
+)
+
+// time to wait for the next mining block after solution is sent,
+// mining block is requested again after it
+const jobTimeout = 10 * time.Second
+
+// count of nonces tried between checks that job is cancelled
+const checkEvery = 1024
+
+// table row of solution, rows are printed as soon as
+// solution is sent, so columns have fixed width
+const solutionRow = "%-8s  %-8s  %-13s  %s\n"
+
+// solution type for send solved mining block
+type solution struct {
+	Index int    `json:"index"`
+	Hash  string `json:"hash"`
+	Nonce string `json:"nonce"`
+	Job   uint64 `json:"job"`
+}
+
+// mine blocks of the node until interrupted
+func mine(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	workers := fs.Int("workers", runtime.NumCPU(), "count of mining goroutines")
+	blocks := fs.Int("blocks", 0, "stop after count of solutions, 0 means never")
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *workers < 1 || *blocks < 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	// mining block changes are received before
+	// the current one is requested to not miss them
+	sub, err := c.Subscribe(ctx, events.Filter{Types: []string{events.MINING}})
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	blk, job, err := miningJob(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if e.format == formatTable {
+		fmt.Fprintf(e.stdout, solutionRow, "BLOCK", "JOB", "NONCE", "HASH")
+	}
+	for mined := 0; *blocks == 0 || mined < *blocks; {
+		// nil block means that solution is sent
+		// and the next mining block is awaited
+		var (
+			found   chan string
+			timeout <-chan time.Time
+			wg      sync.WaitGroup
+		)
+		jobCtx, cancel := context.WithCancel(ctx)
+		if blk != nil {
+			found = make(chan string, *workers)
+			// facts are hashed once, header has their merkle root
+			h := blk.Header()
+			for i := 0; i < *workers; i++ {
+				wg.Add(1)
+				go func(seed int64) {
+					defer wg.Done()
+					solve(jobCtx, *h, seed, found)
+				}(time.Now().UnixNano() + int64(i))
+			}
+		} else {
+			timeout = time.After(jobTimeout)
+		}
+
+		var stale bool
+		select {
+		case nonce := <-found:
+			cancel()
+			err = c.Mine(ctx, nonce, job)
+			if t, ok := err.(*client.Error); ok && t.StatusCode == http.StatusConflict {
+				// mining block is changed meanwhile
+				stale = true
+				break
+			}
+			if err != nil {
+				break
+			}
+
+			mined++
+			s := &solution{Index: blk.Index, Hash: blk.Hash, Nonce: nonce, Job: job}
+			if e.format == formatJSON {
+				err = e.printJSON(s)
+			} else {
+				_, err = fmt.Fprintf(e.stdout, solutionRow, strconv.Itoa(s.Index), strconv.FormatUint(s.Job, 10), s.Nonce, s.Hash)
+			}
+			blk = nil
+		case ev, ok := <-sub.Events():
+			if !ok {
+				err = sub.Err()
+				if err == nil {
+					err = errors.New("node closed event stream")
+				}
+				break
+			}
+			if ev.Job > job {
+				blk, job = ev.Block, ev.Job
+			}
+		case <-timeout:
+			stale = true
+		case <-ctx.Done():
+			// interrupted
+		}
+		cancel()
+		wg.Wait()
+
+		if err != nil || ctx.Err() != nil {
+			return err
+		}
+		if stale {
+			blk, job, err = miningJob(ctx, c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// returns the current mining block and its version
+func miningJob(ctx context.Context, c *client.Client) (*chain.Block, uint64, error) {
+	t, err := c.GetMiningJob(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get mining block: %v", err)
+	}
+	return t.Block, t.Job, nil
+}
+
+// try random nonces until block is solved or job is cancelled
+func solve(ctx context.Context, h chain.Header, seed int64, found chan<- string) {
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; ; i++ {
+		if i%checkEvery == 0 && ctx.Err() != nil {
+			return
+		}
+
+		h.Nonce = strconv.FormatUint(r.Uint64(), 36)
+		if h.Solved() {
+			found <- h.Nonce
+			return
+		}
+	}
+}
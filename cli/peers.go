@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"strconv"
+	"time"
+)
+
+// show connected nodes
+func peers(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	err := e.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+	peers, addr, err := c.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	if e.format == formatJSON {
+		return e.printJSON(map[string]interface{}{"addr": addr, "peers": peers})
+	}
+	rows := [][]string{{"ID", "DIRECTION", "ADDR", "REMOTE ADDR", "HEIGHT", "FIRST BLOCK", "LATENCY", "BAN SCORE", "CONNECTED"}}
+	for _, p := range peers {
+		rows = append(rows, []string{
+			strconv.FormatUint(p.Id, 10),
+			p.Direction,
+			p.Addr,
+			p.RemoteAddr,
+			strconv.Itoa(p.Height),
+			strconv.Itoa(p.FirstBlock),
+			p.Latency.String(),
+			strconv.Itoa(p.BanScore),
+			p.ConnectedAt.Format(time.RFC3339),
+		})
+	}
+	return e.printTable(rows)
+}
@@ -0,0 +1,356 @@
+// Package client implements client of the node http api.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavrs/blkchn/api"
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/p2p"
+	"github.com/lavrs/blkchn/snapshot"
+)
+
+const (
+	// count of retries of failed requests by default
+	defaultRetries = 3
+	// delay before the first retry, doubled after each retry
+	defaultRetryDelay = 200 * time.Millisecond
+)
+
+// Error type for store error sent by node
+type Error struct {
+	// http status code
+	StatusCode int
+	// machine readable error code, empty for legacy endpoints
+	Code    string
+	Message string
+}
+
+// Error returns error message
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("blkchn: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("blkchn: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound reports whether err means that requested item is not found
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Client type for send requests to node
+type Client struct {
+	// node http api url
+	base       *url.URL
+	http       *http.Client
+	retries    int
+	retryDelay time.Duration
+}
+
+// Option type for configure client
+type Option func(*Client)
+
+// WithHTTPClient sets http client used for requests
+func WithHTTPClient(c *http.Client) Option {
+	return func(t *Client) { t.http = c }
+}
+
+// WithRetries sets count of retries of failed requests
+// and delay before the first retry, it is doubled after each
+// retry. Requests which change node state are not retried
+func WithRetries(n int, delay time.Duration) Option {
+	return func(t *Client) { t.retries, t.retryDelay = n, delay }
+}
+
+// New returns client of node with http api address
+// like http://localhost:1000 or localhost:1000
+func New(addr string, opts ...Option) (*Client, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("blkchn: invalid node address %q", addr)
+	}
+
+	c := &Client{
+		base:       u,
+		http:       http.DefaultClient,
+		retries:    defaultRetries,
+		retryDelay: defaultRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// GetBlocks returns at most limit blocks starting from index from
+// and cursor of the next page, limit is chosen by node if zero
+func (c *Client) GetBlocks(ctx context.Context, from, limit int) (*api.BlocksResponse, error) {
+	q := url.Values{"from": {strconv.Itoa(from)}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	t := &api.BlocksResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks", q, nil, t)
+}
+
+// GetBlocksAfter returns blocks of the page after cursor
+func (c *Client) GetBlocksAfter(ctx context.Context, cursor string, limit int) (*api.BlocksResponse, error) {
+	q := url.Values{"cursor": {cursor}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	t := &api.BlocksResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks", q, nil, t)
+}
+
+// GetHeaders returns at most limit block headers starting from index
+// from and cursor of the next page, limit is chosen by node if zero.
+// Headers of blocks before node snapshot are returned too
+func (c *Client) GetHeaders(ctx context.Context, from, limit int) (*api.BlocksResponse, error) {
+	q := url.Values{"from": {strconv.Itoa(from)}, "headers": {"true"}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	t := &api.BlocksResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks", q, nil, t)
+}
+
+// GetHeadersAfter returns block headers of the page after cursor
+func (c *Client) GetHeadersAfter(ctx context.Context, cursor string, limit int) (*api.BlocksResponse, error) {
+	q := url.Values{"cursor": {cursor}, "headers": {"true"}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	t := &api.BlocksResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks", q, nil, t)
+}
+
+// GetBlock returns block by index
+func (c *Client) GetBlock(ctx context.Context, index int) (*chain.Block, error) {
+	t := &chain.Block{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks/"+strconv.Itoa(index), nil, nil, t)
+}
+
+// GetBlockByHash returns block by its hash
+func (c *Client) GetBlockByHash(ctx context.Context, hash string) (*chain.Block, error) {
+	t := &chain.Block{}
+	return t, c.do(ctx, http.MethodGet, "/v1/blocks/"+url.PathEscape(hash), nil, nil, t)
+}
+
+// FactOptions type for store optional fact fields
+type FactOptions struct {
+	// registered fact type
+	Type   string
+	Author string
+}
+
+// SubmitFact sends new fact and returns its id
+func (c *Client) SubmitFact(ctx context.Context, fact interface{}, opts FactOptions) (string, error) {
+	q := url.Values{}
+	if opts.Type != "" {
+		q.Set("type", opts.Type)
+	}
+	if opts.Author != "" {
+		q.Set("author", opts.Author)
+	}
+
+	var t api.FactCreatedResponse
+	return t.Id, c.do(ctx, http.MethodPost, "/v1/facts", q, fact, &t)
+}
+
+// SubmitBlob sends new fact with data kept off-chain and returns
+// its id and hash of data, data of typed fact must be json
+func (c *Client) SubmitBlob(ctx context.Context, data []byte, opts FactOptions) (string, string, error) {
+	q := url.Values{"blob": {"true"}}
+	if opts.Type != "" {
+		q.Set("type", opts.Type)
+	}
+	if opts.Author != "" {
+		q.Set("author", opts.Author)
+	}
+
+	var t api.FactCreatedResponse
+	err := c.do(ctx, http.MethodPost, "/v1/facts", q, raw(data), &t)
+	return t.Id, t.Blob, err
+}
+
+// GetBlob returns fact data kept off-chain by its hash,
+// data is checked against hash
+func (c *Client) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	var data raw
+	err := c.do(ctx, http.MethodGet, "/blobs/"+url.PathEscape(hash), nil, nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	if blob.Hash(data) != hash {
+		return nil, blob.ErrCorrupt
+	}
+	return data, nil
+}
+
+// GetFactStatus returns fact by id and where it is:
+// pending, in mining block or confirmed
+func (c *Client) GetFactStatus(ctx context.Context, id string) (*api.FactResponse, error) {
+	t := &api.FactResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/facts/"+url.PathEscape(id), nil, nil, t)
+}
+
+// GetMiningJob returns mining block and its version
+func (c *Client) GetMiningJob(ctx context.Context) (*api.MiningResponse, error) {
+	t := &api.MiningResponse{}
+	return t, c.do(ctx, http.MethodGet, "/v1/mining", nil, nil, t)
+}
+
+// GetSnapshot returns the latest node state snapshot
+func (c *Client) GetSnapshot(ctx context.Context) (*snapshot.Snapshot, error) {
+	t := &snapshot.Snapshot{}
+	return t, c.do(ctx, http.MethodGet, "/snapshot/latest", nil, nil, t)
+}
+
+// Mine sends solution of mining block of job version,
+// any version is accepted if job is zero
+func (c *Client) Mine(ctx context.Context, nonce string, job uint64) error {
+	q := url.Values{"nonce": {nonce}}
+	if job != 0 {
+		q.Set("job", strconv.FormatUint(job, 10))
+	}
+	return c.do(ctx, http.MethodGet, "/mine", q, nil, nil)
+}
+
+// Peers returns connected nodes and node address for other nodes
+func (c *Client) Peers(ctx context.Context) ([]p2p.PeerInfo, string, error) {
+	var t api.Response
+	err := c.do(ctx, http.MethodGet, "/peers", nil, nil, &t)
+	return t.Peers, t.Addr, err
+}
+
+// raw type for send request body and receive response body as is
+type raw []byte
+
+// send request with json body and decode json response into v,
+// failed requests are retried if it is safe
+func (c *Client) do(ctx context.Context, method, path string, q url.Values, body, v interface{}) error {
+	var (
+		data        []byte
+		contentType = "application/json"
+	)
+	switch t := body.(type) {
+	case nil:
+	case raw:
+		data, contentType = t, "application/octet-stream"
+	default:
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	delay := c.retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := c.try(ctx, method, path, q, data, contentType, v)
+		if err == nil || !retry || attempt == c.retries {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// send request once, returns whether failed request can be retried
+func (c *Client) try(ctx context.Context, method, path string, q url.Values, data []byte, contentType string, v interface{}) (bool, error) {
+	u := *c.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = q.Encode()
+
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	if data != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// request may be handled if it is sent,
+		// so only idempotent requests are retried
+		return ctx.Err() == nil && method != http.MethodPost, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		// error may be sent after request is handled, for example
+		// by proxy, so only idempotent requests are retried
+		retry := method != http.MethodPost &&
+			(resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests)
+		return retry, decodeError(resp)
+	}
+
+	if v == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+	if t, ok := v.(*raw); ok {
+		*t, err = ioutil.ReadAll(resp.Body)
+		return false, err
+	}
+	return false, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// returns error from response, both v1 error objects
+// and error messages of legacy endpoints are decoded
+func decodeError(resp *http.Response) error {
+	e := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	var t struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&t) != nil || len(t.Error) == 0 {
+		return e
+	}
+
+	var v1 api.Error
+	if json.Unmarshal(t.Error, &v1) == nil {
+		e.Code, e.Message = v1.Code, v1.Message
+		return e
+	}
+	var msg string
+	if json.Unmarshal(t.Error, &msg) == nil {
+		e.Message = msg
+	}
+	return e
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/config"
+	"github.com/lavrs/blkchn/events"
+	"github.com/lavrs/blkchn/node"
+)
+
+// starts root node and serves its api by test server,
+// any nonce solves mining block
+func startNode(t *testing.T) (*Client, func()) {
+	cfg := config.Default()
+	cfg.HTTPBind, cfg.WSBind = "127.0.0.1", "127.0.0.1"
+	cfg.HTTPPort, cfg.WSPort = "0", "0"
+	cfg.Difficulty.MaxComplexity = 0
+
+	n, err := node.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(n.Handler())
+
+	c, err := New(srv.URL, WithRetries(2, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+		srv.Close()
+	}
+}
+
+// mines blocks until fact is confirmed
+func confirm(t *testing.T, c *Client, id string) int {
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		err := c.Mine(ctx, "0", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		f, err := c.GetFactStatus(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Status == chain.CONFIRMED {
+			return *f.BlockIndex
+		}
+	}
+	t.Fatal("fact is not confirmed")
+	return 0
+}
+
+func TestBlocks(t *testing.T) {
+	c, stop := startNode(t)
+	defer stop()
+	ctx := context.Background()
+
+	t1, err := c.GetBlocks(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t1.Height != 0 || len(t1.Blocks) != 1 {
+		t.Fatalf("want genesis block only, got height %d and %d blocks", t1.Height, len(t1.Blocks))
+	}
+
+	genesis, err := c.GetBlock(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := c.GetBlockByHash(ctx, genesis.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Index != 0 {
+		t.Fatalf("want block 0 by hash, got %d", blk.Index)
+	}
+
+	_, err = c.GetBlock(ctx, 100)
+	if !IsNotFound(err) {
+		t.Fatalf("want not found error, got %v", err)
+	}
+	if e, ok := err.(*Error); !ok || e.Code != "block_not_found" {
+		t.Fatalf("want block_not_found code, got %v", err)
+	}
+}
+
+func TestFacts(t *testing.T) {
+	c, stop := startNode(t)
+	defer stop()
+	ctx := context.Background()
+
+	id, err := c.SubmitFact(ctx, map[string]int{"n": 1}, FactOptions{Author: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := c.GetFactStatus(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Status != chain.PENDING && f.Status != chain.MINING {
+		t.Fatalf("want unconfirmed fact, got %s", f.Status)
+	}
+	if f.Fact.Author != "test" {
+		t.Fatalf("want author test, got %q", f.Fact.Author)
+	}
+
+	index := confirm(t, c, id)
+	blk, err := c.GetBlock(ctx, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blk.Facts) != 1 || blk.Facts[0].Id != id {
+		t.Fatalf("want fact %s in block %d", id, index)
+	}
+
+	_, err = c.GetFactStatus(ctx, "unknown")
+	if !IsNotFound(err) {
+		t.Fatalf("want not found error, got %v", err)
+	}
+	_, err = c.SubmitFact(ctx, nil, FactOptions{})
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusBadRequest {
+		t.Fatalf("want bad request for null fact, got %v", err)
+	}
+}
+
+func TestMineStaleJob(t *testing.T) {
+	c, stop := startNode(t)
+	defer stop()
+
+	err := c.Mine(context.Background(), "0", 1<<40)
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusConflict || e.Message == "" {
+		t.Fatalf("want conflict with legacy error message, got %v", err)
+	}
+}
+
+func TestPeers(t *testing.T) {
+	c, stop := startNode(t)
+	defer stop()
+
+	peers, addr, err := c.Peers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 0 || addr == "" {
+		t.Fatalf("want no peers and node address, got %d peers and %q", len(peers), addr)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, stop := startNode(t)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, err := c.Subscribe(ctx, events.Filter{Types: []string{events.FACT, events.BLOCK}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	id, err := c.SubmitFact(ctx, "fact", FactOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirm(t, c, id)
+
+	var fact, block bool
+	timeout := time.After(5 * time.Second)
+	for !fact || !block {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				t.Fatalf("subscription is closed: %v", sub.Err())
+			}
+			switch e.Type {
+			case events.FACT:
+				fact = fact || e.Fact.Id == id
+			case events.BLOCK:
+				block = block || len(e.Block.Facts) == 1 && e.Block.Facts[0].Id == id
+			default:
+				t.Fatalf("unexpected event %s", e.Type)
+			}
+		case <-timeout:
+			t.Fatal("events are not received")
+		}
+	}
+
+	_, err = c.Subscribe(ctx, events.Filter{Types: []string{"unknown"}})
+	if e, ok := err.(*Error); !ok || e.Code != "invalid_filter" {
+		t.Fatalf("want invalid_filter error, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"index":42}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk, err := c.GetBlock(context.Background(), 42)
+	if err != nil || blk.Index != 42 {
+		t.Fatalf("want block 42 after retries, got %v, %v", blk, err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	c, _ = New(srv.URL, WithRetries(1, time.Millisecond))
+	_, err = c.GetBlock(context.Background(), 42)
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want unavailable error without enough retries, got %v", err)
+	}
+
+	// node may have taken the fact before error is sent
+	atomic.StoreInt32(&calls, 0)
+	c, _ = New(srv.URL, WithRetries(2, time.Millisecond))
+	_, err = c.SubmitFact(context.Background(), 1, FactOptions{})
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want unavailable error of fact submit, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("want fact submitted once, got %d requests", n)
+	}
+}
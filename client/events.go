@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lavrs/blkchn/events"
+)
+
+// ErrLagged means that node closed subscription
+// because client didn't keep up with events
+var ErrLagged = errors.New("blkchn: subscription is too slow")
+
+// Subscription type for receive node events
+type Subscription struct {
+	events chan *events.Event
+	cancel context.CancelFunc
+	// set before events channel is closed
+	err error
+}
+
+// Events returns channel of events, it is closed when
+// subscription is closed or stream is broken, see Err
+func (s *Subscription) Events() <-chan *events.Event {
+	return s.events
+}
+
+// Err returns error that ended subscription,
+// nil if it is closed by client or node stops
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close stops receiving events
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Subscribe returns subscription to node events matching filter,
+// it is closed when context is done. Events are not retried,
+// events sent while client is disconnected are missed
+func (c *Client) Subscribe(ctx context.Context, f events.Filter) (*Subscription, error) {
+	q := url.Values{}
+	if len(f.Types) != 0 {
+		q.Set("types", strings.Join(f.Types, ","))
+	}
+	if len(f.FactTypes) != 0 {
+		q.Set("fact_type", strings.Join(f.FactTypes, ","))
+	}
+	u := *c.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/events"
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+
+	s := &Subscription{events: make(chan *events.Event), cancel: cancel}
+	go s.read(ctx, resp.Body)
+	return s, nil
+}
+
+// read server-sent events until stream ends
+func (s *Subscription) read(ctx context.Context, body io.ReadCloser) {
+	defer close(s.events)
+	defer body.Close()
+
+	var (
+		r    = bufio.NewReader(body)
+		typ  string
+		data []string
+	)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if ctx.Err() == nil && err != io.EOF {
+				s.err = err
+			}
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			// blank line ends event
+			if len(data) == 0 {
+				continue
+			}
+			if typ == "lagged" {
+				s.err = ErrLagged
+				return
+			}
+
+			e := &events.Event{}
+			err = json.Unmarshal([]byte(strings.Join(data, "\n")), e)
+			if err != nil {
+				s.err = err
+				return
+			}
+			typ, data = "", nil
+
+			select {
+			case s.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		case strings.HasPrefix(line, ":"):
+			// comment keeps stream open
+		case strings.HasPrefix(line, "event:"):
+			typ = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
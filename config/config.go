@@ -0,0 +1,436 @@
+// Package config implements node configuration.
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lavrs/blkchn/blob"
+)
+
+// prefix of environment variables overriding config
+const envPrefix = "BLKCHN_"
+
+const (
+	// EvictReject means that new facts are rejected when mempool is full
+	EvictReject = "reject"
+	// EvictOldest means that oldest facts are dropped when mempool is full
+	EvictOldest = "evict"
+)
+
+// Duration type for store duration in config as string like "10s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses duration from string
+func (d *Duration) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// MarshalJSON returns duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Config type for store node configuration
+type Config struct {
+	// http api server bind address and port
+	HTTPBind string `json:"http_bind"`
+	HTTPPort string `json:"http_port"`
+	// websocket server bind address and port,
+	// if they are equal to http ones, api and p2p
+	// are served by one server
+	WSBind string `json:"ws_bind"`
+	WSPort string `json:"ws_port"`
+	// websocket address advertised to other nodes,
+	// by default ws://localhost:<ws port>/p2p
+	Addr string `json:"addr"`
+	// directory for node data (blocks, peers database),
+	// nothing is stored if empty
+	DataDir string `json:"data_dir"`
+	// http addresses of nodes used to discover other nodes
+	Seeds []string `json:"seeds"`
+	// websocket addresses of nodes which are always kept connected
+	StaticPeers []string `json:"static_peers"`
+	// time allowed to stop node gracefully
+	ShutdownTimeout Duration         `json:"shutdown_timeout"`
+	Mining          MiningConfig     `json:"mining"`
+	Mempool         MempoolConfig    `json:"mempool"`
+	Schemas         SchemasConfig    `json:"schemas"`
+	Difficulty      DifficultyConfig `json:"difficulty"`
+	Snapshots       SnapshotsConfig  `json:"snapshots"`
+	Pruning         PruningConfig    `json:"pruning"`
+	Blobs           BlobsConfig      `json:"blobs"`
+	Log             LogConfig        `json:"log"`
+}
+
+// MiningConfig type for store mining configuration
+type MiningConfig struct {
+	// accept solutions on /mine
+	Enabled bool `json:"enabled"`
+	// max count of facts in block, the rest
+	// of unconfirmed facts wait for next blocks
+	MaxBlockFacts int `json:"max_block_facts"`
+}
+
+// MempoolConfig type for store unconfirmed facts pool limits,
+// zero limit means no limit
+type MempoolConfig struct {
+	MaxFacts    int `json:"max_facts"`
+	MaxBytes    int `json:"max_bytes"`
+	MaxFactSize int `json:"max_fact_size"`
+	// what to do with new fact when pool is full:
+	// "reject" it or "evict" oldest facts
+	Eviction string `json:"eviction"`
+}
+
+// SchemasConfig type for store fact types configuration
+type SchemasConfig struct {
+	// reject facts without registered type
+	RequireType bool `json:"require_type"`
+}
+
+// DifficultyConfig type for store mining complexity configuration
+type DifficultyConfig struct {
+	// complexity increases if blocks are created
+	// faster than this interval, otherwise decreases
+	BlockInterval Duration `json:"block_interval"`
+	MinComplexity int      `json:"min_complexity"`
+	MaxComplexity int      `json:"max_complexity"`
+}
+
+// SnapshotsConfig type for store node state snapshots configuration
+type SnapshotsConfig struct {
+	// take snapshot every interval blocks, zero disables snapshots
+	Interval int `json:"interval"`
+	// start empty node from snapshot of seed node
+	// instead of receiving all blocks
+	Bootstrap bool `json:"bootstrap"`
+	// hash of block trusted by operator, snapshot is taken only
+	// if blocks from it to snapshot tip are valid. Without it
+	// snapshot tip has to be confirmed by another seed node
+	Checkpoint string `json:"checkpoint"`
+}
+
+// PruningConfig type for store facts pruning configuration,
+// facts of old blocks are discarded, their headers are kept
+type PruningConfig struct {
+	// count of the latest blocks kept with facts, zero keeps all blocks
+	KeepBlocks int `json:"keep_blocks"`
+	// max size of blocks kept with facts in bytes, zero means no limit
+	MaxBytes int `json:"max_bytes"`
+}
+
+// BlobsConfig type for store off-chain fact data configuration
+type BlobsConfig struct {
+	// max size of fact data kept off-chain, it is
+	// limited by size of message between nodes
+	MaxSize int `json:"max_size"`
+}
+
+// LogConfig type for store logging configuration
+type LogConfig struct {
+	// enable verbose output
+	Verbose bool `json:"verbose"`
+	// log file path, stderr if empty
+	File string `json:"file"`
+}
+
+// Default returns config with default values
+func Default() *Config {
+	return &Config{
+		ShutdownTimeout: Duration{10 * time.Second},
+		Mining: MiningConfig{
+			Enabled:       true,
+			MaxBlockFacts: 1000,
+		},
+		Mempool: MempoolConfig{
+			MaxFacts:    10000,
+			MaxBytes:    16 << 20,
+			MaxFactSize: 64 << 10,
+			Eviction:    EvictReject,
+		},
+		Difficulty: DifficultyConfig{
+			BlockInterval: Duration{10 * time.Second},
+			MinComplexity: 0,
+			// sha256 hash has 64 hex digits
+			MaxComplexity: 64,
+		},
+		Snapshots: SnapshotsConfig{
+			Interval: 100,
+		},
+		Blobs: BlobsConfig{
+			MaxSize: blob.MaxSize,
+		},
+	}
+}
+
+// Parse parses command line arguments, config file and environment
+// variables into config, flags override environment that overrides file
+func Parse(args []string) (*Config, error) {
+	var (
+		fs   = flag.NewFlagSet("blkchn", flag.ContinueOnError)
+		path = fs.String("c", os.Getenv(envPrefix+"CONFIG"), "set config file path")
+		// initial nodes addrs
+		iNode = fs.String("i", "", "set initial nodes addresses separated by comma")
+		// node http server port
+		hPort = fs.String("h", "", "set node http server port")
+		// node websocket server port
+		wsPort = fs.String("ws", "", "set node websocket server port")
+		// node data directory
+		dataDir = fs.String("d", "", "set node data directory")
+		// verbose output flag
+		v = fs.Bool("v", false, "enable verbose output")
+	)
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *path != "" {
+		err = cfg.load(*path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", *path, err)
+		}
+	}
+
+	err = cfg.loadEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// apply only flags that were set
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "i":
+			cfg.Seeds = append(splitList(*iNode), cfg.Seeds...)
+		case "h":
+			cfg.HTTPPort = *hPort
+		case "ws":
+			cfg.WSPort = *wsPort
+		case "d":
+			cfg.DataDir = *dataDir
+		case "v":
+			cfg.Log.Verbose = *v
+		}
+	})
+
+	return cfg, cfg.Validate()
+}
+
+// read config file, file with .toml extension is in toml,
+// others are in json. Unknown fields are not allowed
+func (cfg *Config) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(path) == ".toml" {
+		data, err = tomlToJSON(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(cfg)
+	if err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after config")
+	}
+	return nil
+}
+
+// override config with environment variables
+func (cfg *Config) loadEnv() error {
+	var (
+		str = func(dst *string) func(string) error {
+			return func(s string) error { *dst = s; return nil }
+		}
+		list = func(dst *[]string) func(string) error {
+			return func(s string) error { *dst = splitList(s); return nil }
+		}
+		boolean = func(dst *bool) func(string) error {
+			return func(s string) (err error) {
+				*dst, err = strconv.ParseBool(s)
+				return err
+			}
+		}
+		integer = func(dst *int) func(string) error {
+			return func(s string) (err error) {
+				*dst, err = strconv.Atoi(s)
+				return err
+			}
+		}
+		duration = func(dst *Duration) func(string) error {
+			return func(s string) (err error) {
+				dst.Duration, err = time.ParseDuration(s)
+				return err
+			}
+		}
+
+		vars = []struct {
+			name  string
+			parse func(string) error
+		}{
+			{"HTTP_BIND", str(&cfg.HTTPBind)},
+			{"HTTP_PORT", str(&cfg.HTTPPort)},
+			{"WS_BIND", str(&cfg.WSBind)},
+			{"WS_PORT", str(&cfg.WSPort)},
+			{"ADDR", str(&cfg.Addr)},
+			{"DATA_DIR", str(&cfg.DataDir)},
+			{"SEEDS", list(&cfg.Seeds)},
+			{"STATIC_PEERS", list(&cfg.StaticPeers)},
+			{"SHUTDOWN_TIMEOUT", duration(&cfg.ShutdownTimeout)},
+			{"MINING_ENABLED", boolean(&cfg.Mining.Enabled)},
+			{"MINING_MAX_BLOCK_FACTS", integer(&cfg.Mining.MaxBlockFacts)},
+			{"MEMPOOL_MAX_FACTS", integer(&cfg.Mempool.MaxFacts)},
+			{"MEMPOOL_MAX_BYTES", integer(&cfg.Mempool.MaxBytes)},
+			{"MEMPOOL_MAX_FACT_SIZE", integer(&cfg.Mempool.MaxFactSize)},
+			{"MEMPOOL_EVICTION", str(&cfg.Mempool.Eviction)},
+			{"SCHEMAS_REQUIRE_TYPE", boolean(&cfg.Schemas.RequireType)},
+			{"DIFFICULTY_BLOCK_INTERVAL", duration(&cfg.Difficulty.BlockInterval)},
+			{"DIFFICULTY_MIN_COMPLEXITY", integer(&cfg.Difficulty.MinComplexity)},
+			{"DIFFICULTY_MAX_COMPLEXITY", integer(&cfg.Difficulty.MaxComplexity)},
+			{"SNAPSHOTS_INTERVAL", integer(&cfg.Snapshots.Interval)},
+			{"SNAPSHOTS_BOOTSTRAP", boolean(&cfg.Snapshots.Bootstrap)},
+			{"SNAPSHOTS_CHECKPOINT", str(&cfg.Snapshots.Checkpoint)},
+			{"PRUNING_KEEP_BLOCKS", integer(&cfg.Pruning.KeepBlocks)},
+			{"PRUNING_MAX_BYTES", integer(&cfg.Pruning.MaxBytes)},
+			{"BLOBS_MAX_SIZE", integer(&cfg.Blobs.MaxSize)},
+			{"LOG_VERBOSE", boolean(&cfg.Log.Verbose)},
+			{"LOG_FILE", str(&cfg.Log.File)},
+		}
+	)
+
+	for _, v := range vars {
+		s, ok := os.LookupEnv(envPrefix + v.name)
+		if !ok {
+			continue
+		}
+		err := v.parse(s)
+		if err != nil {
+			return fmt.Errorf("%s%s: %v", envPrefix, v.name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks config and returns all found problems in one error
+func (cfg *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(isPort(cfg.HTTPPort), "invalid http port %q", cfg.HTTPPort)
+	check(isPort(cfg.WSPort), "invalid websocket port %q", cfg.WSPort)
+	check(isBind(cfg.HTTPBind), "invalid http bind address %q", cfg.HTTPBind)
+	check(isBind(cfg.WSBind), "invalid websocket bind address %q", cfg.WSBind)
+	check(cfg.Addr == "" || isWSURL(cfg.Addr), "invalid advertised address %q", cfg.Addr)
+
+	if cfg.DataDir != "" {
+		fi, err := os.Stat(cfg.DataDir)
+		check(os.IsNotExist(err) || err == nil && fi.IsDir(),
+			"data dir %q is not a directory", cfg.DataDir)
+	}
+	for _, seed := range cfg.Seeds {
+		_, _, err := net.SplitHostPort(seed)
+		check(err == nil, "invalid seed address %q, want host:port", seed)
+	}
+	for _, addr := range cfg.StaticPeers {
+		check(isWSURL(addr), "invalid static peer address %q, want ws://host:port/p2p", addr)
+	}
+
+	check(cfg.ShutdownTimeout.Duration > 0, "shutdown timeout must be positive")
+
+	check(cfg.Mining.MaxBlockFacts >= 0, "max block facts must not be negative")
+
+	m := cfg.Mempool
+	check(m.MaxFacts >= 0, "mempool max facts must not be negative")
+	check(m.MaxBytes >= 0, "mempool max bytes must not be negative")
+	check(m.MaxFactSize >= 0, "mempool max fact size must not be negative")
+	check(m.Eviction == EvictReject || m.Eviction == EvictOldest,
+		"invalid mempool eviction %q, want %q or %q", m.Eviction, EvictReject, EvictOldest)
+
+	d := cfg.Difficulty
+	check(d.BlockInterval.Duration > 0, "block interval must be positive")
+	check(d.MinComplexity >= 0, "min complexity must not be negative")
+	check(d.MaxComplexity <= 64, "max complexity must not be greater than 64")
+	check(d.MinComplexity <= d.MaxComplexity, "min complexity is greater than max complexity")
+
+	check(cfg.Snapshots.Interval >= 0, "snapshots interval must not be negative")
+	check(cfg.Snapshots.Checkpoint == "" || isHash(cfg.Snapshots.Checkpoint),
+		"invalid snapshots checkpoint %q, want block hash", cfg.Snapshots.Checkpoint)
+
+	check(cfg.Pruning.KeepBlocks >= 0, "pruning keep blocks must not be negative")
+	check(cfg.Pruning.MaxBytes >= 0, "pruning max bytes must not be negative")
+
+	check(cfg.Blobs.MaxSize > 0 && cfg.Blobs.MaxSize <= blob.MaxSize,
+		"blobs max size must be from 1 to %d", blob.MaxSize)
+
+	if len(errs) != 0 {
+		return errors.New("invalid config:\n\t" + strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// check that port is a number in allowed range,
+// zero means any free port
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 0 && port < 1<<16
+}
+
+// check that s is sha256 hash in hex
+func isHash(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && len(s) == 64
+}
+
+// check that bind address is empty (all interfaces) or ip
+func isBind(s string) bool {
+	return s == "" || net.ParseIP(s) != nil
+}
+
+// check that address is websocket url
+func isWSURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "ws" || u.Scheme == "wss") && u.Host != ""
+}
+
+// split comma separated list skipping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
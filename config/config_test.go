@@ -0,0 +1,133 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writes config file with name and returns its path
+func writeConfig(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		// part of error message, empty if config is valid
+		err string
+	}{
+		{
+			name: "json",
+			file: "config.json",
+			data: `{"http_port": "1001", "ws_port": "2001", "seeds": ["localhost:1000"],
+				"mempool": {"max_facts": 5}, "difficulty": {"block_interval": "1m"}}`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			data: `
+# node ports
+http_port = "1001"
+ws_port = '2001'
+seeds = ["localhost:1000"] # seed nodes
+
+[mempool]
+max_facts = 5
+
+[difficulty]
+block_interval = "1m"
+`,
+		},
+		{
+			name: "unknown json field",
+			file: "config.json",
+			data: `{"http_port": "1001", "ws_port": "2001", "http_prot": "1002"}`,
+			err:  `unknown field "http_prot"`,
+		},
+		{
+			name: "unknown toml field",
+			file: "config.toml",
+			data: "http_port = \"1001\"\nws_port = \"2001\"\n[mempool]\nmax_fact = 5\n",
+			err:  `unknown field "max_fact"`,
+		},
+		{
+			name: "data after json",
+			file: "config.json",
+			data: `{"http_port": "1001", "ws_port": "2001"} {}`,
+			err:  "unexpected data after config",
+		},
+		{
+			name: "invalid toml",
+			file: "config.toml",
+			data: "http_port = \"1001\"\nws_port 2001\n",
+			err:  "line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]string{"-c", writeConfig(t, tt.file, tt.data)})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Default()
+			want.HTTPPort, want.WSPort = "1001", "2001"
+			want.Seeds = []string{"localhost:1000"}
+			want.Mempool.MaxFacts = 5
+			want.Difficulty.BlockInterval.Duration = time.Minute
+			if !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("want config %+v, got %+v", want, cfg)
+			}
+		})
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"http_port": "1001", "ws_port": "2001", "data_dir": "file"}`)
+	os.Setenv(envPrefix+"WS_PORT", "2002")
+	os.Setenv(envPrefix+"DATA_DIR", "env")
+	defer os.Unsetenv(envPrefix + "WS_PORT")
+	defer os.Unsetenv(envPrefix + "DATA_DIR")
+
+	cfg, err := Parse([]string{"-c", path, "-d", filepath.Join(t.TempDir(), "flag")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flags override environment, environment overrides file
+	if cfg.HTTPPort != "1001" || cfg.WSPort != "2002" || filepath.Base(cfg.DataDir) != "flag" {
+		t.Fatalf("want ports 1001, 2002 and data dir of flag, got %s, %s and %s", cfg.HTTPPort, cfg.WSPort, cfg.DataDir)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.HTTPPort, cfg.WSPort = "1001", "70000"
+	cfg.Mempool.Eviction = "drop"
+	cfg.Difficulty.MinComplexity = 65
+
+	err := cfg.Validate()
+	for _, want := range []string{"invalid websocket port", "invalid mempool eviction", "min complexity is greater"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("want error %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bare key or dotted table name
+var (
+	keyFormat   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tableFormat = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+)
+
+// converts config in toml to json, only part of toml used by config
+// is supported: tables, bare keys, strings, integers, booleans and
+// arrays of them written on one line
+func tomlToJSON(data []byte) ([]byte, error) {
+	root := make(map[string]interface{})
+	table := root
+	for i, line := range strings.Split(string(data), "\n") {
+		err := parseLine(root, &table, strings.TrimSpace(line))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+// parse line of toml into table, table header changes current table
+func parseLine(root map[string]interface{}, table *map[string]interface{}, line string) error {
+	if line == "" || line[0] == '#' {
+		return nil
+	}
+
+	if line[0] == '[' {
+		end := strings.IndexByte(line, ']')
+		if end < 0 || !isComment(line[end+1:]) {
+			return fmt.Errorf("invalid table header %q", line)
+		}
+		name := strings.TrimSpace(line[1:end])
+		if !tableFormat.MatchString(name) {
+			return fmt.Errorf("invalid table name %q", name)
+		}
+
+		t := root
+		for _, key := range strings.Split(name, ".") {
+			next, ok := t[key]
+			if !ok {
+				next = make(map[string]interface{})
+				t[key] = next
+			}
+			sub, ok := next.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s is not a table", key)
+			}
+			t = sub
+		}
+		*table = t
+		return nil
+	}
+
+	eq := strings.IndexByte(line, '=')
+	if eq < 0 {
+		return fmt.Errorf("want key = value, got %q", line)
+	}
+	key := strings.TrimSpace(line[:eq])
+	if !keyFormat.MatchString(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	if _, ok := (*table)[key]; ok {
+		return fmt.Errorf("duplicate key %s", key)
+	}
+
+	v, rest, err := parseValue(strings.TrimSpace(line[eq+1:]))
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	if !isComment(rest) {
+		return fmt.Errorf("%s: unexpected %q after value", key, rest)
+	}
+	(*table)[key] = v
+	return nil
+}
+
+// parse value at the start of s, returns value and the rest of s
+func parseValue(s string) (interface{}, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case s[0] == '"':
+		return parseString(s)
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[':
+		return parseArray(s)
+	}
+
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	n, err := strconv.ParseInt(strings.Replace(word, "_", "", -1), 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid value %q", word)
+	}
+	return n, rest, nil
+}
+
+// parse basic string with escapes at the start of s
+func parseString(s string) (interface{}, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return nil, "", fmt.Errorf("unterminated string")
+			}
+			i++
+			switch s[i] {
+			case '"', '\\':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return nil, "", fmt.Errorf("unsupported escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated string")
+}
+
+// parse array written on one line at the start of s
+func parseArray(s string) (interface{}, string, error) {
+	items := []interface{}{}
+	s = strings.TrimSpace(s[1:])
+	for {
+		if s != "" && s[0] == ']' {
+			return items, s[1:], nil
+		}
+		v, rest, err := parseValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, v)
+
+		s = strings.TrimSpace(rest)
+		if s != "" && s[0] == ',' {
+			s = strings.TrimSpace(s[1:])
+		} else if s == "" || s[0] != ']' {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+	}
+}
+
+// check that the rest of line is empty or comment
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
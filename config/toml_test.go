@@ -0,0 +1,58 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTOMLToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+		json string
+		// part of error message
+		err string
+	}{
+		{
+			name: "values",
+			toml: `s = "a \"b\"\t"
+l = 'c\d'
+n = 1_000
+b = true
+a = [1, "x", false]
+e = []`,
+			json: `{"a":[1,"x",false],"b":true,"e":[],"l":"c\\d","n":1000,"s":"a \"b\"\t"}`,
+		},
+		{
+			name: "tables",
+			toml: "a = 1\n[t]\nb = 2\n[t.u] # nested\nc = 3\n",
+			json: `{"a":1,"t":{"b":2,"u":{"c":3}}}`,
+		},
+		{name: "missing value", toml: "a =", err: "line 1: a: missing value"},
+		{name: "duplicate key", toml: "a = 1\na = 2", err: "line 2: duplicate key a"},
+		{name: "unterminated string", toml: `a = "b`, err: "unterminated string"},
+		{name: "unterminated array", toml: `a = [1, 2`, err: "unterminated array"},
+		{name: "data after value", toml: `a = 1 2`, err: "unexpected"},
+		{name: "value is not table", toml: "a = 1\n[a]", err: "a is not a table"},
+		{name: "invalid table", toml: "[a b]", err: "invalid table name"},
+		{name: "float", toml: "a = 1.5", err: "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tomlToJSON([]byte(tt.toml))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("want %s, got %s", tt.json, data)
+			}
+		})
+	}
+}
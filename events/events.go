@@ -0,0 +1,203 @@
+// Package events implements notifications of clients about node changes.
+package events
+
+import (
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// size of the subscription event queue, subscription
+// is closed if client doesn't keep up with events
+const queueSize = 256
+
+const (
+	// BLOCK means that block is confirmed
+	BLOCK = "block"
+	// MINING means that mining block is changed
+	MINING = "mining"
+	// FACT means that new unconfirmed fact is received
+	FACT = "fact"
+	// REORG means that blockchain is replaced starting from fork block
+	REORG = "reorg"
+)
+
+// Types contains all event types
+var Types = []string{BLOCK, MINING, FACT, REORG}
+
+// Reorg type for send blockchain replacement
+type Reorg struct {
+	// index of the first replaced block
+	Fork      int `json:"fork"`
+	OldHeight int `json:"old_height"`
+	NewHeight int `json:"new_height"`
+}
+
+// Event type for send node change to clients
+type Event struct {
+	// sequence number of the event
+	Id   uint64 `json:"id"`
+	Type string `json:"type"`
+	// confirmed or mining block
+	Block *chain.Block `json:"block,omitempty"`
+	// mining block version
+	Job   uint64      `json:"job,omitempty"`
+	Fact  *chain.Fact `json:"fact,omitempty"`
+	Reorg *Reorg      `json:"reorg,omitempty"`
+}
+
+// Filter type for select events, zero value selects all events
+type Filter struct {
+	// event types
+	Types []string
+	// fact types, blocks are sent only with facts of these types
+	FactTypes []string
+}
+
+// returns event matching filter or nil, block is copied
+// if some of its facts are filtered out
+func (f *Filter) apply(e *Event) *Event {
+	if len(f.Types) != 0 && !contains(f.Types, e.Type) {
+		return nil
+	}
+	if len(f.FactTypes) == 0 {
+		return e
+	}
+
+	switch {
+	case e.Fact != nil:
+		if !contains(f.FactTypes, e.Fact.Type) {
+			return nil
+		}
+	case e.Block != nil:
+		var facts []*chain.Fact
+		for _, fact := range e.Block.Facts {
+			if contains(f.FactTypes, fact.Type) {
+				facts = append(facts, fact)
+			}
+		}
+		if len(facts) == 0 {
+			return nil
+		}
+		if len(facts) != len(e.Block.Facts) {
+			blk := *e.Block
+			blk.Facts = facts
+			t := *e
+			t.Block = &blk
+			return &t
+		}
+	}
+	return e
+}
+
+// check that list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription type for receive events
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	events chan *Event
+	// set when events are dropped because of full queue
+	lagged bool
+}
+
+// Events returns channel of events, it is closed when subscription
+// is closed, client doesn't keep up with events or bus is closed
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Lagged reports whether subscription is closed
+// because client didn't keep up with events
+func (s *Subscription) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.lagged
+}
+
+// Close stops receiving events
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
+
+// Bus type for deliver events to subscriptions
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	lastId uint64
+	closed bool
+}
+
+// NewBus returns bus without subscriptions
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns subscription to events matching filter
+func (b *Bus) Subscribe(f Filter) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription{bus: b, filter: f, events: make(chan *Event, queueSize)}
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Publish numbers event and sends it to subscriptions without
+// waiting, subscriptions with full queue are closed
+func (b *Bus) Publish(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	e.Id = b.lastId
+
+	for s := range b.subs {
+		t := s.filter.apply(e)
+		if t == nil {
+			continue
+		}
+		select {
+		case s.events <- t:
+		default:
+			s.lagged = true
+			b.remove(s)
+		}
+	}
+}
+
+// Close closes all subscriptions, new ones are closed at once
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// remove subscription and close its channel, lock must be held
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.events)
+}
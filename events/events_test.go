@@ -0,0 +1,130 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// returns events received by subscription until its queue is empty
+func received(s *Subscription) []*Event {
+	var events []*Event
+	for {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	blk := &chain.Block{Index: 1, Facts: []*chain.Fact{{Id: "a", Type: "x"}, {Id: "b", Type: "y"}}}
+	tests := []struct {
+		name   string
+		filter Filter
+		event  *Event
+		// ids of facts of delivered event, nil if event is filtered out
+		facts []string
+	}{
+		{name: "all events", event: &Event{Type: BLOCK, Block: blk}, facts: []string{"a", "b"}},
+		{name: "other type", filter: Filter{Types: []string{FACT}}, event: &Event{Type: BLOCK, Block: blk}},
+		{
+			name:   "block facts of type",
+			filter: Filter{FactTypes: []string{"y"}},
+			event:  &Event{Type: BLOCK, Block: blk},
+			facts:  []string{"b"},
+		},
+		{name: "block without facts of type", filter: Filter{FactTypes: []string{"z"}}, event: &Event{Type: BLOCK, Block: blk}},
+		{
+			name:   "fact of type",
+			filter: Filter{FactTypes: []string{"x"}},
+			event:  &Event{Type: FACT, Fact: blk.Facts[0]},
+			facts:  []string{"a"},
+		},
+		{name: "fact of other type", filter: Filter{FactTypes: []string{"y"}}, event: &Event{Type: FACT, Fact: blk.Facts[0]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.filter.apply(tt.event)
+			if tt.facts == nil {
+				if e != nil {
+					t.Fatalf("want event filtered out, got %+v", e)
+				}
+				return
+			}
+			if e == nil {
+				t.Fatalf("want event with facts %v, got nothing", tt.facts)
+			}
+
+			var ids []string
+			if e.Fact != nil {
+				ids = append(ids, e.Fact.Id)
+			} else {
+				for _, fact := range e.Block.Facts {
+					ids = append(ids, fact.Id)
+				}
+			}
+			if len(ids) != len(tt.facts) || ids[0] != tt.facts[0] {
+				t.Fatalf("want facts %v, got %v", tt.facts, ids)
+			}
+		})
+	}
+	// filtered block is a copy
+	if len(blk.Facts) != 2 {
+		t.Fatalf("want block facts kept, got %d", len(blk.Facts))
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := NewBus()
+	all := b.Subscribe(Filter{})
+	facts := b.Subscribe(Filter{Types: []string{FACT}})
+
+	b.Publish(&Event{Type: MINING})
+	b.Publish(&Event{Type: FACT, Fact: &chain.Fact{Id: "a"}})
+
+	events := received(all)
+	if len(events) != 2 || events[0].Id != 1 || events[1].Id != 2 {
+		t.Fatalf("want 2 numbered events, got %d", len(events))
+	}
+	if events := received(facts); len(events) != 1 || events[0].Type != FACT {
+		t.Fatalf("want only fact event, got %d events", len(events))
+	}
+
+	// closed subscription doesn't receive events
+	facts.Close()
+	b.Publish(&Event{Type: FACT, Fact: &chain.Fact{Id: "b"}})
+	if _, ok := <-facts.Events(); ok {
+		t.Fatalf("want events of closed subscription closed")
+	}
+}
+
+func TestLagged(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(Filter{})
+	for i := 0; i <= queueSize; i++ {
+		b.Publish(&Event{Type: MINING})
+	}
+
+	if events := received(s); len(events) != queueSize || !s.Lagged() {
+		t.Fatalf("want subscription closed after %d events, got %d events and lagged %v", queueSize, len(events), s.Lagged())
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(Filter{})
+	b.Close()
+	if _, ok := <-s.Events(); ok {
+		t.Fatalf("want subscription closed with bus")
+	}
+	if _, ok := <-b.Subscribe(Filter{}).Events(); ok {
+		t.Fatalf("want new subscription of closed bus closed")
+	}
+}
@@ -0,0 +1,229 @@
+// Package mempool implements pool of unconfirmed facts.
+package mempool
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+var (
+	// ErrFactTooLarge means that fact is larger than max fact size
+	ErrFactTooLarge = errors.New("fact is too large")
+	// ErrPoolFull means that pool has no room for fact
+	ErrPoolFull = errors.New("unconfirmed facts pool is full")
+	// ErrDuplicate means that fact with the same id is already in pool
+	ErrDuplicate = errors.New("fact is already in pool")
+	// ErrConfirmed means that fact with the same id is already
+	// confirmed in blockchain or taken by mining block
+	ErrConfirmed = errors.New("fact is already confirmed")
+)
+
+// Limits type for store pool limits, zero value means no limit
+type Limits struct {
+	// max count of facts
+	MaxFacts int
+	// max total size of facts in bytes
+	MaxBytes int
+	// max size of single fact in bytes
+	MaxFactSize int
+	// evict oldest facts to make room for new one
+	// instead of rejecting it when pool is full
+	EvictOldest bool
+}
+
+// Stats type for send pool usage
+type Stats struct {
+	Count       int `json:"count"`
+	Bytes       int `json:"bytes"`
+	MaxFacts    int `json:"max_facts,omitempty"`
+	MaxBytes    int `json:"max_bytes,omitempty"`
+	MaxFactSize int `json:"max_fact_size,omitempty"`
+}
+
+// unconfirmed fact with its size
+type entry struct {
+	fact *chain.Fact
+	size int
+}
+
+// Pool type for store unconfirmed facts
+type Pool struct {
+	mu     sync.Mutex
+	limits Limits
+	// facts in order of arrival
+	entries []entry
+	// fact id -> fact
+	ids   map[string]*chain.Fact
+	bytes int
+	// checks that fact is confirmed or taken by mining block
+	confirmed func(id string) bool
+}
+
+// New returns empty pool with limits
+func New(l Limits) *Pool {
+	return &Pool{limits: l, ids: make(map[string]*chain.Fact)}
+}
+
+// SetConfirmed sets check of facts that are already confirmed
+// in blockchain or taken by mining block, such facts are not added
+func (p *Pool) SetConfirmed(confirmed func(id string) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.confirmed = confirmed
+}
+
+// Size returns size of fact in bytes as it is sent
+func Size(fact *chain.Fact) int {
+	data, err := json.Marshal(fact.Fact)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// Add appends fact to unconfirmed facts, returns error if fact
+// is too large, pool is full or fact is already confirmed.
+// Evicted facts are returned
+func (p *Pool) Add(fact *chain.Fact) ([]*chain.Fact, error) {
+	size := Size(fact)
+	if p.limits.MaxFactSize > 0 && size > p.limits.MaxFactSize {
+		return nil, ErrFactTooLarge
+	}
+	if p.limits.MaxBytes > 0 && size > p.limits.MaxBytes {
+		return nil, ErrPoolFull
+	}
+
+	p.mu.Lock()
+	confirmed := p.confirmed
+	p.mu.Unlock()
+	// checked without lock, miner holds its lock
+	// while it removes facts from pool
+	if confirmed != nil && confirmed(fact.Id) {
+		return nil, ErrConfirmed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.ids[fact.Id]; ok {
+		return nil, ErrDuplicate
+	}
+
+	var evicted []*chain.Fact
+	for p.full(size) {
+		if !p.limits.EvictOldest {
+			return nil, ErrPoolFull
+		}
+		evicted = append(evicted, p.entries[0].fact)
+		p.drop(0)
+	}
+
+	p.entries = append(p.entries, entry{fact: fact, size: size})
+	p.ids[fact.Id] = fact
+	p.bytes += size
+	return evicted, nil
+}
+
+// check that fact of size doesn't fit in pool, lock must be held
+func (p *Pool) full(size int) bool {
+	return p.limits.MaxFacts > 0 && len(p.entries) >= p.limits.MaxFacts ||
+		p.limits.MaxBytes > 0 && p.bytes+size > p.limits.MaxBytes
+}
+
+// remove entry by position, lock must be held
+func (p *Pool) drop(i int) {
+	delete(p.ids, p.entries[i].fact.Id)
+	p.bytes -= p.entries[i].size
+	p.entries = append(p.entries[:i], p.entries[i+1:]...)
+}
+
+// Take returns at most n oldest unconfirmed facts and removes
+// them from the pool, all facts are taken if n <= 0
+func (p *Pool) Take(n int) []*chain.Fact {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n <= 0 || n > len(p.entries) {
+		n = len(p.entries)
+	}
+
+	facts := make([]*chain.Fact, n)
+	for i, e := range p.entries[:n] {
+		facts[i] = e.fact
+		delete(p.ids, e.fact.Id)
+		p.bytes -= e.size
+	}
+	p.entries = append([]entry(nil), p.entries[n:]...)
+
+	if len(facts) == 0 {
+		return nil
+	}
+	return facts
+}
+
+// Remove removes facts confirmed in block from the pool
+func (p *Pool) Remove(blk *chain.Block) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// check on the repetition of facts
+	for _, tFact := range blk.Facts {
+		if _, ok := p.ids[tFact.Id]; !ok {
+			continue
+		}
+		for i, e := range p.entries {
+			if e.fact.Id == tFact.Id {
+				// if found -> remove fact
+				p.drop(i)
+				break
+			}
+		}
+	}
+}
+
+// Get returns unconfirmed fact by id
+func (p *Pool) Get(id string) (*chain.Fact, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fact, ok := p.ids[id]
+	return fact, ok
+}
+
+// Facts returns all unconfirmed facts
+func (p *Pool) Facts() []*chain.Fact {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	facts := make([]*chain.Fact, len(p.entries))
+	for i, e := range p.entries {
+		facts[i] = e.fact
+	}
+	return facts
+}
+
+// Len returns count of unconfirmed facts
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.entries)
+}
+
+// Stats returns pool usage and limits
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return Stats{
+		Count:       len(p.entries),
+		Bytes:       p.bytes,
+		MaxFacts:    p.limits.MaxFacts,
+		MaxBytes:    p.limits.MaxBytes,
+		MaxFactSize: p.limits.MaxFactSize,
+	}
+}
@@ -0,0 +1,188 @@
+package mempool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// returns fact with string data of size bytes in json
+func newFact(id string, size int) *chain.Fact {
+	var data interface{} = strings.Repeat("x", size-2)
+	return &chain.Fact{Id: id, Fact: &data}
+}
+
+// returns ids of facts
+func ids(facts []*chain.Fact) []string {
+	var t []string
+	for _, fact := range facts {
+		t = append(t, fact.Id)
+	}
+	return t
+}
+
+func TestAdd(t *testing.T) {
+	type add struct {
+		id   string
+		size int
+		err  error
+		// ids of evicted facts
+		evicted []string
+	}
+	tests := []struct {
+		name   string
+		limits Limits
+		adds   []add
+		// ids of facts left in pool
+		facts []string
+		bytes int
+	}{
+		{
+			name:   "no limits",
+			limits: Limits{},
+			adds:   []add{{id: "a", size: 10}, {id: "b", size: 20}},
+			facts:  []string{"a", "b"},
+			bytes:  30,
+		},
+		{
+			name:   "duplicate",
+			limits: Limits{},
+			adds:   []add{{id: "a", size: 10}, {id: "a", size: 10, err: ErrDuplicate}},
+			facts:  []string{"a"},
+			bytes:  10,
+		},
+		{
+			name:   "fact too large",
+			limits: Limits{MaxFactSize: 10},
+			adds:   []add{{id: "a", size: 10}, {id: "b", size: 11, err: ErrFactTooLarge}},
+			facts:  []string{"a"},
+			bytes:  10,
+		},
+		{
+			name:   "max facts rejects",
+			limits: Limits{MaxFacts: 2},
+			adds:   []add{{id: "a", size: 5}, {id: "b", size: 5}, {id: "c", size: 5, err: ErrPoolFull}},
+			facts:  []string{"a", "b"},
+			bytes:  10,
+		},
+		{
+			name:   "max bytes rejects",
+			limits: Limits{MaxBytes: 25},
+			adds:   []add{{id: "a", size: 10}, {id: "b", size: 10}, {id: "c", size: 10, err: ErrPoolFull}},
+			facts:  []string{"a", "b"},
+			bytes:  20,
+		},
+		{
+			name:   "fact larger than pool",
+			limits: Limits{MaxBytes: 25, EvictOldest: true},
+			adds:   []add{{id: "a", size: 10}, {id: "b", size: 30, err: ErrPoolFull}},
+			facts:  []string{"a"},
+			bytes:  10,
+		},
+		{
+			name:   "max facts evicts oldest",
+			limits: Limits{MaxFacts: 2, EvictOldest: true},
+			adds: []add{
+				{id: "a", size: 5}, {id: "b", size: 5},
+				{id: "c", size: 5, evicted: []string{"a"}},
+			},
+			facts: []string{"b", "c"},
+			bytes: 10,
+		},
+		{
+			name:   "max bytes evicts until fact fits",
+			limits: Limits{MaxBytes: 30, EvictOldest: true},
+			adds: []add{
+				{id: "a", size: 10}, {id: "b", size: 10}, {id: "c", size: 10},
+				{id: "d", size: 25, evicted: []string{"a", "b", "c"}},
+			},
+			facts: []string{"d"},
+			bytes: 25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.limits)
+			for _, a := range tt.adds {
+				evicted, err := p.Add(newFact(a.id, a.size))
+				if err != a.err {
+					t.Fatalf("add %s: want error %v, got %v", a.id, a.err, err)
+				}
+				if !reflect.DeepEqual(ids(evicted), a.evicted) {
+					t.Fatalf("add %s: want evicted %v, got %v", a.id, a.evicted, ids(evicted))
+				}
+			}
+
+			if got := ids(p.Facts()); !reflect.DeepEqual(got, tt.facts) {
+				t.Fatalf("want facts %v, got %v", tt.facts, got)
+			}
+			if s := p.Stats(); s.Count != len(tt.facts) || s.Bytes != tt.bytes {
+				t.Fatalf("want %d facts of %d bytes, got %d of %d", len(tt.facts), tt.bytes, s.Count, s.Bytes)
+			}
+		})
+	}
+}
+
+func TestTakeAndRemove(t *testing.T) {
+	tests := []struct {
+		name string
+		take int
+		// facts confirmed in block after take
+		confirmed []string
+		taken     []string
+		left      []string
+	}{
+		{name: "take some", take: 2, taken: []string{"a", "b"}, left: []string{"c", "d"}},
+		{name: "take all", take: 0, taken: []string{"a", "b", "c", "d"}},
+		{name: "take more than pool", take: 10, taken: []string{"a", "b", "c", "d"}},
+		{name: "remove confirmed", take: 1, confirmed: []string{"c", "x"}, taken: []string{"a"}, left: []string{"b", "d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(Limits{})
+			for _, id := range []string{"a", "b", "c", "d"} {
+				p.Add(newFact(id, 10))
+			}
+
+			taken := p.Take(tt.take)
+			blk := &chain.Block{}
+			for _, id := range tt.confirmed {
+				blk.Facts = append(blk.Facts, newFact(id, 10))
+			}
+			p.Remove(blk)
+
+			if !reflect.DeepEqual(ids(taken), tt.taken) {
+				t.Fatalf("want taken %v, got %v", tt.taken, ids(taken))
+			}
+			if got := ids(p.Facts()); !reflect.DeepEqual(got, tt.left) {
+				t.Fatalf("want left %v, got %v", tt.left, got)
+			}
+			if s := p.Stats(); s.Bytes != 10*len(tt.left) {
+				t.Fatalf("want %d bytes, got %d", 10*len(tt.left), s.Bytes)
+			}
+			for _, id := range tt.taken {
+				if _, ok := p.Get(id); ok {
+					t.Fatalf("taken fact %s is still in pool", id)
+				}
+			}
+		})
+	}
+}
+
+func TestAddConfirmed(t *testing.T) {
+	p := New(Limits{})
+	p.SetConfirmed(func(id string) bool { return id == "a" })
+
+	_, err := p.Add(newFact("a", 10))
+	if err != ErrConfirmed {
+		t.Fatalf("want error %v, got %v", ErrConfirmed, err)
+	}
+	_, err = p.Add(newFact("b", 10))
+	if err != nil || p.Len() != 1 {
+		t.Fatalf("want fact added, got %v and %d facts", err, p.Len())
+	}
+}
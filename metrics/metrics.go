@@ -0,0 +1,189 @@
+// Package metrics implements node metrics written
+// in prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is content type of metrics in text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Counter type for count events by label value
+type Counter struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// NewCounter returns counter with zero values of labels,
+// so they are written before the first event
+func NewCounter(labels ...string) *Counter {
+	c := &Counter{values: make(map[string]uint64)}
+	for _, l := range labels {
+		c.values[l] = 0
+	}
+	return c
+}
+
+// Inc counts event with label value
+func (c *Counter) Inc(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[label]++
+}
+
+// Values returns count of events by label value
+func (c *Counter) Values() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	values := make(map[string]float64, len(c.values))
+	for l, v := range c.values {
+		values[l] = float64(v)
+	}
+	return values
+}
+
+// Histogram type for count observed values in buckets
+type Histogram struct {
+	mu sync.Mutex
+	// upper bounds of buckets in ascending order
+	buckets []float64
+	// count of values in each bucket, not cumulative
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns histogram with buckets upper bounds,
+// values greater than the last one are counted only in +Inf
+func NewHistogram(buckets ...float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe adds value to histogram
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// Writer type for write metrics, the first error
+// stops writing and is returned by Err
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns writer of metrics to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first write error
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Gauge writes metric with current value
+func (w *Writer) Gauge(name, help string, v float64) {
+	w.header(name, help, "gauge")
+	w.sample(name, "", v)
+}
+
+// GaugeVec writes metric with current values by label values
+func (w *Writer) GaugeVec(name, help, label string, values map[string]float64) {
+	w.header(name, help, "gauge")
+	w.vec(name, label, values)
+}
+
+// Counter writes counter with its values by label values
+func (w *Writer) Counter(name, help, label string, c *Counter) {
+	w.header(name, help, "counter")
+	w.vec(name, label, c.Values())
+}
+
+// Histogram writes histogram with cumulative buckets
+func (w *Writer) Histogram(name, help string, h *Histogram) {
+	h.mu.Lock()
+	var (
+		buckets = h.buckets
+		counts  = append([]uint64(nil), h.counts...)
+		sum     = h.sum
+		count   = h.count
+	)
+	h.mu.Unlock()
+
+	w.header(name, help, "histogram")
+	var total uint64
+	for i, b := range buckets {
+		total += counts[i]
+		w.sample(name+"_bucket", labels("le", formatFloat(b)), float64(total))
+	}
+	w.sample(name+"_bucket", labels("le", "+Inf"), float64(count))
+	w.sample(name+"_sum", "", sum)
+	w.sample(name+"_count", "", float64(count))
+}
+
+// write help and type lines of metric
+func (w *Writer) header(name, help, typ string) {
+	w.printf("# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	w.printf("# TYPE %s %s\n", name, typ)
+}
+
+// write samples sorted by label values
+func (w *Writer) vec(name, label string, values map[string]float64) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.sample(name, labels(label, k), values[k])
+	}
+}
+
+// write sample line
+func (w *Writer) sample(name, labels string, v float64) {
+	w.printf("%s%s %s\n", name, labels, formatFloat(v))
+}
+
+// write formatted text unless previous write failed
+func (w *Writer) printf(format string, a ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = fmt.Fprintf(w.w, format, a...)
+}
+
+// returns label set with escaped value
+func labels(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return "{" + name + `="` + value + `"}`
+}
+
+// returns value in format of text exposition
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
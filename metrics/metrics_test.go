@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	c := NewCounter("a", "b")
+	c.Inc("b")
+	c.Inc("c\"")
+
+	h := NewHistogram(1, 5)
+	for _, v := range []float64{0.5, 1, 3, 10} {
+		h.Observe(v)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Gauge("height", "latest block\nindex", 3)
+	w.GaugeVec("peers", "peers by direction", "direction", map[string]float64{"out": 2, "in": 1})
+	w.Counter("invalid_total", "invalid blocks", "reason", c)
+	w.Histogram("interval_seconds", "block interval", h)
+	w.Gauge("inf", "infinite value", math.Inf(1))
+	if w.Err() != nil {
+		t.Fatal(w.Err())
+	}
+
+	want := `# HELP height latest block\nindex
+# TYPE height gauge
+height 3
+# HELP peers peers by direction
+# TYPE peers gauge
+peers{direction="in"} 1
+peers{direction="out"} 2
+# HELP invalid_total invalid blocks
+# TYPE invalid_total counter
+invalid_total{reason="a"} 0
+invalid_total{reason="b"} 1
+invalid_total{reason="c\""} 1
+# HELP interval_seconds block interval
+# TYPE interval_seconds histogram
+interval_seconds_bucket{le="1"} 2
+interval_seconds_bucket{le="5"} 3
+interval_seconds_bucket{le="+Inf"} 4
+interval_seconds_sum 14.5
+interval_seconds_count 4
+# HELP inf infinite value
+# TYPE inf gauge
+inf +Inf
+`
+	if buf.String() != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, buf.String())
+	}
+}
+
+// writer failing after limit bytes
+type failWriter struct {
+	limit int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, errors.New("write failed")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriterErr(t *testing.T) {
+	fw := &failWriter{limit: 10}
+	w := NewWriter(fw)
+	w.Gauge("height", "latest block index", 3)
+	w.Gauge("other", "other value", 1)
+	if w.Err() == nil || fw.limit != 10 {
+		t.Fatalf("want the first error kept and nothing written after it, got %v", w.Err())
+	}
+}
@@ -0,0 +1,171 @@
+// Package miner implements checking of mining solutions.
+package miner
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/mempool"
+)
+
+// ErrStaleJob means that solution is for replaced mining block
+var ErrStaleJob = errors.New("mining block has been replaced")
+
+// Miner type for store current mining block
+type Miner struct {
+	mu         sync.Mutex
+	chain      *chain.Chain
+	pool       *mempool.Pool
+	difficulty chain.Difficulty
+	// max count of facts in block, unlimited if zero
+	maxFacts int
+	// mining block
+	block *chain.Block
+	// mining block version, increased on each change
+	// so miners know to switch to the new block
+	job uint64
+}
+
+// New returns miner of blocks for the blockchain with at most
+// maxFacts facts from the pool in each block
+func New(c *chain.Chain, pool *mempool.Pool, d chain.Difficulty, maxFacts int) *Miner {
+	return &Miner{chain: c, pool: pool, difficulty: d, maxFacts: maxFacts}
+}
+
+// Block returns current mining block
+func (m *Miner) Block() *chain.Block {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.block
+}
+
+// Job returns current mining block and its version
+func (m *Miner) Job() (*chain.Block, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.block, m.job
+}
+
+// SetBlock updates mining block, for example received from other node.
+// Its facts are removed from the pool, facts of previous mining block
+// which are neither in new one nor confirmed are returned to the pool
+func (m *Miner) SetBlock(blk *chain.Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := m.block
+	m.set(blk)
+	if blk != nil {
+		m.pool.Remove(blk)
+	}
+	if prev == nil {
+		return
+	}
+
+	taken := make(map[string]bool)
+	if blk != nil {
+		for _, fact := range blk.Facts {
+			taken[fact.Id] = true
+		}
+	}
+	for _, fact := range prev.Facts {
+		if taken[fact.Id] {
+			continue
+		}
+		if t, _ := m.chain.FindFact(fact.Id); t != nil {
+			continue
+		}
+		m.pool.Add(fact)
+	}
+}
+
+// Reset creates next mining block after latest block
+// with unconfirmed facts, the rest of them wait for next blocks
+func (m *Miner) Reset() *chain.Block {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(chain.NextBlock(m.chain.Latest(), m.pool.Take(m.maxFacts), m.difficulty))
+	return m.block
+}
+
+// Refresh adds unconfirmed facts to mining block if it has room
+// for them, returns true if mining block is changed. Facts which
+// are already in mining block are kept
+func (m *Miner) Refresh() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.block == nil {
+		return false
+	}
+	n := m.maxFacts - len(m.block.Facts)
+	if m.maxFacts > 0 && n <= 0 {
+		return false
+	}
+	facts := append([]*chain.Fact(nil), m.block.Facts...)
+	taken := make(map[string]bool, len(facts))
+	for _, fact := range facts {
+		taken[fact.Id] = true
+	}
+	for _, fact := range m.pool.Take(n) {
+		// fact can be received again from other node
+		if !taken[fact.Id] {
+			facts = append(facts, fact)
+		}
+	}
+	if len(facts) == len(m.block.Facts) {
+		return false
+	}
+
+	blk := *m.block
+	blk.Facts = facts
+	blk.Nonce = ""
+	// facts are part of block hash
+	blk.Hash = chain.CalcHash(blk.String())
+	m.set(&blk)
+	return true
+}
+
+// replace mining block and bump its version, lock must be held
+func (m *Miner) set(blk *chain.Block) {
+	m.block = blk
+	m.job++
+}
+
+// Try checks that nonce solves mining block of job version, any
+// version is accepted if job is zero. If so block is appended
+// to the blockchain and next mining block is created
+func (m *Miner) Try(nonce string, job uint64) (*chain.VMBlocks, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.block == nil || job != 0 && job != m.job {
+		return nil, false
+	}
+
+	// update nonce
+	blk := *m.block
+	blk.Nonce = nonce
+
+	// solve a task
+	if !Solves(&blk) {
+		return nil, false
+	}
+	// if solved -> validate and append block
+	if m.chain.Append(&blk) != nil {
+		return nil, false
+	}
+
+	m.set(chain.NextBlock(&blk, m.pool.Take(m.maxFacts), m.difficulty))
+	return &chain.VMBlocks{ValidBlock: &blk, MiningBlock: m.block}, true
+}
+
+// Solves checks that hash of block with its nonce
+// has enough leading zeros
+func Solves(blk *chain.Block) bool {
+	return blk.Solved()
+}
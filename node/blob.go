@@ -0,0 +1,190 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/p2p"
+	"github.com/lavrs/blkchn/schema"
+)
+
+// time allowed to receive blob from other nodes
+const blobWait = 10 * time.Second
+
+// SubmitBlob adds new unconfirmed fact with data kept off-chain
+// in blob store, fact has only hash of its data. Data of typed fact
+// must be json matching schema of its type
+func (n *Node) SubmitBlob(typ, author string, data []byte) (*chain.Fact, error) {
+	if len(data) == 0 {
+		return nil, schema.ErrNullFact
+	}
+	if len(data) > n.cfg.Blobs.MaxSize {
+		return nil, blob.ErrTooLarge
+	}
+	if typ != "" {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		if err != nil {
+			return nil, &schema.ValidationError{Path: "$", Message: "data of typed fact must be json"}
+		}
+		err = n.schemas.Validate(&chain.Fact{Type: typ, Fact: &v})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopping {
+		return nil, ErrStopped
+	}
+
+	t := &chain.Fact{
+		Id:     chain.CalcHash(time.Now().String()),
+		Type:   typ,
+		Author: author,
+		Blob:   blob.Hash(data),
+	}
+	err := n.schemas.Validate(t)
+	if err != nil {
+		return nil, err
+	}
+	n.log.Println("New fact notice", t.Id, typ, "blob", t.Blob)
+
+	// blob is kept only for accepted fact
+	err = n.addFact(t)
+	if err != nil {
+		return nil, err
+	}
+	_, err = n.blobs.Put(data)
+	if err != nil {
+		n.pool.Remove(&chain.Block{Facts: []*chain.Fact{t}})
+		return nil, err
+	}
+	n.refreshMining()
+	n.network.Broadcast(&p2p.Message{Type: p2p.FACT, Fact: t})
+
+	return t, nil
+}
+
+// Blob returns fact data kept off-chain by its hash, blob missing
+// in store is requested from connected nodes if known fact has it
+func (n *Node) Blob(ctx context.Context, hash string) ([]byte, error) {
+	if !blob.IsHash(hash) {
+		return nil, blob.ErrNotFound
+	}
+
+	data, err := n.blobs.Get(hash)
+	if err == blob.ErrCorrupt {
+		n.log.Println("Blob", hash, "is damaged, request it from nodes")
+	} else if err != blob.ErrNotFound {
+		return data, err
+	}
+	if !n.knownBlob(hash) {
+		return nil, blob.ErrNotFound
+	}
+
+	current := n.network.Peers()
+	if len(current) == 0 {
+		return nil, blob.ErrNotFound
+	}
+
+	// each node responds once, with data or without it
+	found := make(chan []byte, len(current))
+	n.blobMu.Lock()
+	n.blobWaits[hash] = append(n.blobWaits[hash], found)
+	n.blobMu.Unlock()
+	defer n.stopWait(hash, found)
+
+	for _, p := range current {
+		p.Send(&p2p.Message{Type: p2p.GETBLOB, Hash: hash})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, blobWait)
+	defer cancel()
+	for range current {
+		select {
+		case data := <-found:
+			if data == nil {
+				continue
+			}
+			_, err := n.blobs.Put(data)
+			if err != nil {
+				n.log.Println("Failed to store blob", hash+":", err)
+			}
+			return data, nil
+		case <-ctx.Done():
+			return nil, blob.ErrNotFound
+		}
+	}
+	return nil, blob.ErrNotFound
+}
+
+// checks that blob is data of fact of kept blocks,
+// mining block or unconfirmed facts
+func (n *Node) knownBlob(hash string) bool {
+	if n.chain.HasBlob(hash) {
+		return true
+	}
+	facts := n.pool.Facts()
+	if blk := n.miner.Block(); blk != nil {
+		facts = append(facts, blk.Facts...)
+	}
+	for _, fact := range facts {
+		if fact.Blob == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// stop waiting for blob responses
+func (n *Node) stopWait(hash string, found chan []byte) {
+	n.blobMu.Lock()
+	defer n.blobMu.Unlock()
+
+	waits := n.blobWaits[hash]
+	for i, t := range waits {
+		if t == found {
+			waits = append(waits[:i], waits[i+1:]...)
+			break
+		}
+	}
+	if len(waits) == 0 {
+		delete(n.blobWaits, hash)
+	} else {
+		n.blobWaits[hash] = waits
+	}
+}
+
+// handle blob request and response of node
+func (n *Node) handleBlob(p *p2p.Peer, m *p2p.Message) {
+	switch m.Type {
+	case p2p.GETBLOB:
+		// send blob or response without data if it is missing
+		data, err := n.blobs.Get(m.Hash)
+		if err != nil && err != blob.ErrNotFound {
+			n.log.Println("Failed to read blob", m.Hash+":", err)
+		}
+		p.Send(&p2p.Message{Type: p2p.BLOB, Hash: m.Hash, Data: data})
+	case p2p.BLOB:
+		data := m.Data
+		if data != nil && blob.Hash(data) != m.Hash {
+			p.Misbehave(20, "blob doesn't match its hash")
+			data = nil
+		}
+
+		n.blobMu.Lock()
+		defer n.blobMu.Unlock()
+		for _, found := range n.blobWaits[m.Hash] {
+			select {
+			case found <- data:
+			default:
+				// node has already responded
+			}
+		}
+	}
+}
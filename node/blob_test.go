@@ -0,0 +1,72 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+	"github.com/lavrs/blkchn/mempool"
+)
+
+func TestSubmitBlob(t *testing.T) {
+	cfg := testConfig()
+	cfg.Mempool.MaxFacts = 1
+	n, stop := startNode(t, cfg, chaintest.NewChain(2))
+	defer stop()
+
+	fact, err := n.SubmitBlob("", "", []byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := n.Blob(context.Background(), fact.Blob)
+	if err != nil || string(data) != "first" {
+		t.Fatalf("want blob of accepted fact, got %q and %v", data, err)
+	}
+
+	// fact doesn't fit in pool, so its blob is not kept
+	var v interface{} = "data"
+	err = n.addFact(&chain.Fact{Id: "pooled", Fact: &v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = n.SubmitBlob("", "", []byte("second"))
+	if err != mempool.ErrPoolFull {
+		t.Fatalf("want error %v, got %v", mempool.ErrPoolFull, err)
+	}
+	_, err = n.blobs.Get(blob.Hash([]byte("second")))
+	if err != blob.ErrNotFound {
+		t.Fatalf("want blob of refused fact not stored, got %v", err)
+	}
+}
+
+func TestKnownBlob(t *testing.T) {
+	blocks := chaintest.NewChain(2)
+	confirmed := blob.Hash([]byte("confirmed"))
+	blocks = append(blocks, chaintest.Mine(blocks[1], &chain.Fact{Id: "blob", Blob: confirmed}))
+	n, stop := startNode(t, testConfig(), blocks)
+	defer stop()
+
+	pooled := blob.Hash([]byte("pooled"))
+	err := n.addFact(&chain.Fact{Id: "pooled", Blob: pooled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mining := blob.Hash([]byte("mining"))
+	n.miner.SetBlock(chain.NextBlock(blocks[2], []*chain.Fact{{Id: "mining", Blob: mining}}, chaintest.Difficulty))
+
+	for _, hash := range []string{confirmed, pooled, mining} {
+		if !n.knownBlob(hash) {
+			t.Fatalf("want blob %s known", hash)
+		}
+	}
+	unknown := blob.Hash([]byte("unknown"))
+	if n.knownBlob(unknown) {
+		t.Fatalf("want blob %s unknown", unknown)
+	}
+	_, err = n.Blob(context.Background(), unknown)
+	if err != blob.ErrNotFound {
+		t.Fatalf("want error %v, got %v", blob.ErrNotFound, err)
+	}
+}
@@ -0,0 +1,300 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/lavrs/blkchn/api"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/events"
+	"github.com/lavrs/blkchn/p2p"
+)
+
+const (
+	// time allowed to receive blockchain from other nodes
+	syncWait = 30 * time.Second
+	// period of checking that static nodes are connected
+	staticPeriod = 10 * time.Second
+	// max count and size of blocks sent in one message, at
+	// least one block is sent. Websocket message is up to 32MB
+	pageBlocks = 1000
+	pageBytes  = 8 << 20
+)
+
+// ask seed node for its address and addresses of its nodes
+func discover(seed string) ([]string, error) {
+	var t *api.Response
+
+	r, err := http.Get("http://" + seed + "/peers")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	err = json.NewDecoder(r.Body).Decode(&t)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []string{t.Addr}
+	for _, p := range t.Peers {
+		addrs = append(addrs, p.Addr)
+	}
+	return addrs, nil
+}
+
+// connect to seed, static and known nodes and
+// receive blockchain from them, returns false
+// if no one node is reachable. Empty node may start
+// from snapshot of seed node and receive only
+// blocks following it
+func (n *Node) bootstrap() (bool, error) {
+	var (
+		addrs   []string
+		visited = map[string]bool{n.Addr(): true}
+	)
+
+	if n.cfg.Snapshots.Bootstrap && n.Height() < 0 {
+		n.restoreSnapshot()
+	}
+
+	for _, seed := range n.cfg.Seeds {
+		found, err := discover(seed)
+		if err != nil {
+			n.log.Println("Failed to discover nodes from", seed, "seed:", err)
+			continue
+		}
+		n.log.Println("Nodes discovered from", seed, "seed", found)
+		addrs = append(addrs, found...)
+	}
+	addrs = append(addrs, n.cfg.StaticPeers...)
+	addrs = append(addrs, n.peersDB.Addrs()...)
+
+	// connect to each nodes
+	for _, addr := range addrs {
+		if visited[addr] {
+			continue
+		}
+		visited[addr] = true
+
+		_, err := n.network.Connect(addr)
+		if err != nil {
+			// node may be already gone
+			n.log.Println("Failed to connect to", addr, "node:", err)
+		}
+	}
+
+	current := n.network.Peers()
+	if len(current) == 0 {
+		return false, nil
+	}
+
+	// request blocks we don't have and wait for the first response
+	for _, p := range current {
+		n.requestBlocks(p, n.Height()+1)
+	}
+	select {
+	case <-n.synced:
+		return true, nil
+	case <-time.After(syncWait):
+		return false, errors.New("failed to receive blockchain from nodes")
+	}
+}
+
+// replace blockchain with received one if it is longer and valid,
+// received blocks may follow our ones
+func (n *Node) syncBlockchain(p *p2p.Peer, blocks []*chain.Block, mining *chain.Block) {
+	err := n.checkFacts(blocks)
+	if err != nil {
+		n.metrics.invalidBlocks.Inc(invalidFact)
+		p.Misbehave(50, "invalid blockchain: "+err.Error())
+		return
+	}
+
+	old, height := n.chain.Blocks(), n.Height()
+	replaced, err := n.chain.Replace(blocks)
+	if err == chain.ErrInvalidBlock {
+		n.rejectBlocks(blocks)
+		p.Misbehave(50, "invalid blockchain")
+		return
+	}
+	if err != nil {
+		n.log.Println("Failed to store blockchain:", err)
+		return
+	}
+
+	if replaced {
+		n.log.Println("Blockchain received from", p.Addr(), "node")
+		n.publishReplace(old, n.chain.Blocks())
+		n.observeBlocks(n.chain.Range(height+1, n.Height()-height))
+		n.webhooks.Notify()
+		n.adoptMining(mining)
+		n.publishMining()
+		n.refreshMining()
+		n.prune()
+		n.takeSnapshot()
+		p.SetHeight(n.Height())
+	} else if len(blocks) != 0 && blocks[0].Index == height+1 &&
+		blocks[len(blocks)-1].Index > height {
+		// longer blockchain forks before our latest block,
+		// request all its blocks from full node if possible
+		n.requestBlocks(n.fullPeer(p), 0)
+		return
+	} else if n.Height() < 0 && len(blocks) != 0 {
+		// blocks can't be taken without earlier ones
+		n.log.Println("Node", p.Addr(), "is pruned, it doesn't have blocks before",
+			blocks[0].Index, "but its snapshot can be used to start blockchain")
+		return
+	} else if n.miner.Block() == nil && n.Height() >= 0 {
+		// stored blockchain is up to date,
+		// mine the same block as the node if possible
+		n.adoptMining(mining)
+		n.publishMining()
+		n.refreshMining()
+	}
+	// our blockchain is up to date
+	n.syncOnce.Do(func() { close(n.synced) })
+}
+
+// request blocks starting from index from node
+func (n *Node) requestBlocks(p *p2p.Peer, from int) {
+	n.reqMu.Lock()
+	n.blockReqs[p.Id()]++
+	n.reqMu.Unlock()
+
+	p.Send(&p2p.Message{Type: p2p.GETBLOCKS, From: from})
+}
+
+// mark blockchain request to node as answered,
+// returns false if node is not asked for blocks
+func (n *Node) answered(p *p2p.Peer) bool {
+	n.reqMu.Lock()
+	defer n.reqMu.Unlock()
+
+	if n.blockReqs[p.Id()] == 0 {
+		return false
+	}
+	n.blockReqs[p.Id()]--
+	if n.blockReqs[p.Id()] == 0 {
+		delete(n.blockReqs, p.Id())
+	}
+	return true
+}
+
+// returns blocks starting from index fitting in one message and
+// index of the block following them, zero if they are the latest
+func (n *Node) blocksPage(from int) ([]*chain.Block, int) {
+	blocks := n.chain.Range(from, pageBlocks)
+	size := 0
+	for i, blk := range blocks {
+		size += chain.Size(blk)
+		if i > 0 && size > pageBytes {
+			blocks = blocks[:i]
+			break
+		}
+	}
+	if len(blocks) == 0 || blocks[len(blocks)-1].Index >= n.Height() {
+		return blocks, 0
+	}
+	return blocks, blocks[len(blocks)-1].Index + 1
+}
+
+// add page of blockchain received from node to its previous pages,
+// returns blocks of all pages after the last page. Returns false
+// if next page doesn't follow the page
+func (n *Node) addPage(p *p2p.Peer, blocks []*chain.Block, next int) ([]*chain.Block, bool) {
+	n.reqMu.Lock()
+	defer n.reqMu.Unlock()
+
+	if next != 0 && (len(blocks) == 0 || next != blocks[len(blocks)-1].Index+1) {
+		delete(n.pages, p.Id())
+		return nil, false
+	}
+	prev := n.pages[p.Id()]
+	if len(prev) != 0 && (len(blocks) == 0 || blocks[0].Index != prev[len(prev)-1].Index+1) {
+		// page answers another request, blockchain is sent again
+		prev = nil
+	}
+	blocks = append(prev, blocks...)
+	if next != 0 {
+		n.pages[p.Id()] = blocks
+		return nil, true
+	}
+	delete(n.pages, p.Id())
+	return blocks, true
+}
+
+// returns connected node having facts of all blocks,
+// p if there is no one
+func (n *Node) fullPeer(p *p2p.Peer) *p2p.Peer {
+	if !p.Info().Pruned {
+		return p
+	}
+	for _, t := range n.network.Peers() {
+		if info := t.Info(); info.Version != 0 && !info.Pruned {
+			return t
+		}
+	}
+	return p
+}
+
+// reconnect to static nodes when they disconnect
+func (n *Node) keepStatic() {
+	ticker := time.NewTicker(staticPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, addr := range n.cfg.StaticPeers {
+				if n.network.IsConnected(addr) {
+					continue
+				}
+
+				n.log.Println("Reconnect to", addr, "static node")
+				_, err := n.network.Connect(addr)
+				if err != nil {
+					n.log.Println("Failed to connect to", addr, "static node:", err)
+				}
+			}
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// notify clients of blocks replacing old ones, reorg is sent
+// first if some of old blocks are replaced. Blocks are matched
+// by index, kept blocks may start after genesis block
+func (n *Node) publishReplace(old, blocks []*chain.Block) {
+	oldHeight := -1
+	hashes := make(map[int]string, len(old))
+	for _, blk := range old {
+		hashes[blk.Index] = blk.Hash
+		oldHeight = blk.Index
+	}
+
+	fork := oldHeight + 1
+	for _, blk := range blocks {
+		if hash, ok := hashes[blk.Index]; ok && hash != blk.Hash {
+			fork = blk.Index
+			break
+		}
+	}
+
+	if fork <= oldHeight {
+		n.log.Println("Blockchain reorg from block", fork)
+		n.events.Publish(&events.Event{Type: events.REORG, Reorg: &events.Reorg{
+			Fork:      fork,
+			OldHeight: oldHeight,
+			NewHeight: blocks[len(blocks)-1].Index,
+		}})
+	}
+	for _, blk := range blocks {
+		if blk.Index >= fork {
+			n.events.Publish(&events.Event{Type: events.BLOCK, Block: blk})
+		}
+	}
+}
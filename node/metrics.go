@@ -0,0 +1,145 @@
+package node
+
+import (
+	"io"
+	"math"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/metrics"
+	"github.com/lavrs/blkchn/p2p"
+)
+
+// count of the latest blocks hashrate is estimated by
+const hashrateBlocks = 10
+
+var (
+	// buckets of time between blocks in seconds
+	intervalBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+	// buckets of count of facts in block
+	factsBuckets = []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000}
+)
+
+// results of mining attempts
+const (
+	// nonce solved mining block
+	attemptSolved = "solved"
+	// nonce didn't solve mining block
+	attemptUnsolved = "unsolved"
+	// nonce is for replaced mining block
+	attemptStale = "stale"
+)
+
+// reason of rejected block which fact doesn't match schema of its type
+const invalidFact = "invalid_fact"
+
+// nodeMetrics type for count node events for metrics
+type nodeMetrics struct {
+	blockInterval  *metrics.Histogram
+	blockFacts     *metrics.Histogram
+	invalidBlocks  *metrics.Counter
+	miningAttempts *metrics.Counter
+}
+
+// returns metrics without events
+func newMetrics() *nodeMetrics {
+	return &nodeMetrics{
+		blockInterval:  metrics.NewHistogram(intervalBuckets...),
+		blockFacts:     metrics.NewHistogram(factsBuckets...),
+		invalidBlocks:  metrics.NewCounter(chain.BROKEN_LINK, chain.BAD_HASH, chain.INSUFFICIENT_POW, chain.BAD_COMPLEXITY, invalidFact),
+		miningAttempts: metrics.NewCounter(attemptSolved, attemptUnsolved, attemptStale),
+	}
+}
+
+// WriteMetrics writes node metrics in prometheus text exposition format
+func (n *Node) WriteMetrics(w io.Writer) error {
+	mw := metrics.NewWriter(w)
+
+	mw.Gauge("blkchn_chain_height", "Index of the latest block, -1 until blockchain is received.", float64(n.Height()))
+	mw.Gauge("blkchn_chain_complexity", "Complexity of the current mining block.", float64(n.complexity()))
+	mw.Histogram("blkchn_block_interval_seconds", "Time between appended block and previous block.", n.metrics.blockInterval)
+	mw.Histogram("blkchn_block_facts", "Count of facts in appended blocks.", n.metrics.blockFacts)
+	mw.Counter("blkchn_invalid_blocks_total", "Blocks received from nodes and rejected as invalid by reason.", "reason", n.metrics.invalidBlocks)
+
+	stats := n.pool.Stats()
+	mw.Gauge("blkchn_mempool_facts", "Count of unconfirmed facts.", float64(stats.Count))
+	mw.Gauge("blkchn_mempool_bytes", "Size of unconfirmed facts.", float64(stats.Bytes))
+
+	peers := map[string]float64{p2p.INBOUND: 0, p2p.OUTBOUND: 0}
+	for _, p := range n.network.Peers() {
+		peers[p.Info().Direction]++
+	}
+	mw.GaugeVec("blkchn_peers", "Count of connected nodes by direction.", "direction", peers)
+	sent, recv := n.network.Messages()
+	mw.Counter("blkchn_p2p_messages_sent_total", "Messages sent to nodes by type.", "type", sent)
+	mw.Counter("blkchn_p2p_messages_received_total", "Messages received from nodes by type.", "type", recv)
+
+	mw.Counter("blkchn_mining_attempts_total", "Mining solutions checked by result.", "result", n.metrics.miningAttempts)
+	mw.Gauge("blkchn_hashrate", "Hashes per second estimated by complexity and time of the latest blocks.", n.hashrate())
+
+	return mw.Err()
+}
+
+// count blocks appended to blockchain
+func (n *Node) observeBlocks(blocks []*chain.Block) {
+	if len(blocks) == 0 {
+		return
+	}
+
+	var prev *chain.Header
+	if i := blocks[0].Index; i > 0 {
+		if headers := n.chain.Headers(i-1, 1); len(headers) != 0 {
+			prev = headers[0]
+		}
+	}
+	for _, blk := range blocks {
+		if prev != nil {
+			n.metrics.blockInterval.Observe(blk.Timestamp.Sub(prev.Timestamp).Seconds())
+		}
+		n.metrics.blockFacts.Observe(float64(len(blk.Facts)))
+		prev = blk.Header()
+	}
+}
+
+// count invalid blocks received from node
+func (n *Node) rejectBlocks(blocks []*chain.Block) {
+	reason := chain.Invalid(blocks, n.difficulty())
+	if reason == "" {
+		// blocks don't follow blockchain changed meanwhile
+		reason = chain.BROKEN_LINK
+	}
+	n.metrics.invalidBlocks.Inc(reason)
+}
+
+// returns complexity of mining block or of the latest block
+func (n *Node) complexity() int {
+	if blk := n.miner.Block(); blk != nil {
+		return blk.Complexity
+	}
+	if blk := n.chain.Latest(); blk != nil {
+		return blk.Complexity
+	}
+	return 0
+}
+
+// estimate hashrate of nodes by the latest blocks, block of
+// complexity c takes 16^c hashes on average
+func (n *Node) hashrate() float64 {
+	from := n.Height() - hashrateBlocks
+	if from < 0 {
+		from = 0
+	}
+	headers := n.chain.Headers(from, hashrateBlocks+1)
+	if len(headers) < 2 {
+		return 0
+	}
+
+	var hashes float64
+	for _, h := range headers[1:] {
+		hashes += math.Pow(16, float64(h.Complexity))
+	}
+	elapsed := headers[len(headers)-1].Timestamp.Sub(headers[0].Timestamp).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return hashes / elapsed
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	blocks := chaintest.NewChain(3)
+	n, stop := startNode(t, testConfig(), blocks)
+	defer stop()
+
+	// block with broken hash is counted by its reason
+	bad := chaintest.Mine(blocks[2])
+	bad.Hash = chain.CalcHash("other")
+	n.rejectBlocks([]*chain.Block{blocks[2], bad})
+
+	var buf bytes.Buffer
+	err := n.WriteMetrics(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"blkchn_chain_height 2\n",
+		`blkchn_invalid_blocks_total{reason="` + chain.BAD_HASH + `"} 1` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("want metrics with %q, got\n%s", want, buf.String())
+		}
+	}
+}
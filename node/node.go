@@ -0,0 +1,878 @@
+// Package node implements blockchain node combining blockchain,
+// unconfirmed facts, mining, nodes communication and http api.
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lavrs/blkchn/api"
+	"github.com/lavrs/blkchn/blob"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/config"
+	"github.com/lavrs/blkchn/events"
+	"github.com/lavrs/blkchn/mempool"
+	"github.com/lavrs/blkchn/miner"
+	"github.com/lavrs/blkchn/p2p"
+	"github.com/lavrs/blkchn/schema"
+	"github.com/lavrs/blkchn/snapshot"
+	"github.com/lavrs/blkchn/store"
+	"github.com/lavrs/blkchn/webhook"
+)
+
+// ErrStopped means that node is stopping and doesn't accept new work
+var ErrStopped = errors.New("node is stopped")
+
+// Node type for store node state
+type Node struct {
+	cfg *config.Config
+	log *log.Logger
+	// log file, nil if log is written to stderr
+	logFile io.Closer
+
+	chain   *chain.Chain
+	pool    *mempool.Pool
+	miner   *miner.Miner
+	network *p2p.Network
+	peersDB *p2p.PeersDB
+	// blocks storage, nil without data dir
+	store    *store.Store
+	schemas  *schema.Registry
+	events   *events.Bus
+	webhooks *webhook.Dispatcher
+	metrics  *nodeMetrics
+
+	// fact data kept off-chain
+	blobs *blob.Store
+	// blob hash -> requests waiting for it from nodes
+	blobWaits map[string][]chan []byte
+	blobMu    sync.Mutex
+
+	// node id -> count of blockchain requests without response,
+	// blocks nobody asked for are not taken
+	blockReqs map[uint64]int
+	// node id -> blocks of received pages of blockchain
+	// being sent in pages, it is synced after the last page
+	pages map[uint64][]*chain.Block
+	reqMu sync.Mutex
+
+	// latest state snapshot
+	snapshot *snapshot.Snapshot
+	snapMu   sync.Mutex
+
+	httpServer *http.Server
+	wsServer   *http.Server
+	httpLn     net.Listener
+	wsLn       net.Listener
+
+	// closed when blockchain received from other node
+	synced   chan struct{}
+	syncOnce sync.Once
+	// closed when node stops
+	done chan struct{}
+
+	mu sync.Mutex
+	// set when node starts stopping
+	stopping bool
+	// in-flight mining attempts
+	mining sync.WaitGroup
+}
+
+// New returns node configured with cfg, node has to be started
+func New(cfg *config.Config) (*Node, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		cfg:   cfg,
+		chain: chain.New(nil),
+		pool: mempool.New(mempool.Limits{
+			MaxFacts:    cfg.Mempool.MaxFacts,
+			MaxBytes:    cfg.Mempool.MaxBytes,
+			MaxFactSize: cfg.Mempool.MaxFactSize,
+			EvictOldest: cfg.Mempool.Eviction == config.EvictOldest,
+		}),
+		events:    events.NewBus(),
+		metrics:   newMetrics(),
+		blobWaits: make(map[string][]chan []byte),
+		blockReqs: make(map[uint64]int),
+		pages:     make(map[uint64][]*chain.Block),
+		synced:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	// print info log only in verbose mode
+	var out io.Writer = ioutil.Discard
+	if cfg.Log.Verbose {
+		out = os.Stderr
+		if cfg.Log.File != "" {
+			f, err := os.OpenFile(cfg.Log.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			out, n.logFile = f, f
+		}
+	}
+	n.log = log.New(out, "", log.LstdFlags)
+
+	n.peersDB, err = p2p.LoadPeersDB(cfg.DataDir)
+	if err != nil {
+		n.closeLog()
+		return nil, err
+	}
+
+	n.schemas, err = schema.LoadRegistry(cfg.DataDir, cfg.Schemas.RequireType)
+	if err != nil {
+		n.closeLog()
+		return nil, err
+	}
+
+	n.blobs, err = blob.Open(cfg.DataDir)
+	if err != nil {
+		n.closeLog()
+		return nil, err
+	}
+
+	if cfg.DataDir != "" {
+		n.store, n.chain, err = store.Open(cfg.DataDir)
+		if err != nil {
+			n.closeLog()
+			return nil, err
+		}
+		n.chain.SetStore(n.store)
+
+		n.snapshot, err = snapshot.Load(cfg.DataDir)
+		if err != nil {
+			n.closeStore()
+			n.closeLog()
+			return nil, err
+		}
+	}
+
+	n.webhooks, err = webhook.Load(cfg.DataDir, n.chain, n.log)
+	if err != nil {
+		n.closeStore()
+		n.closeLog()
+		return nil, err
+	}
+
+	n.chain.SetDifficulty(n.difficulty())
+	n.miner = miner.New(n.chain, n.pool, n.difficulty(), cfg.Mining.MaxBlockFacts)
+	n.pool.SetConfirmed(n.confirmed)
+	n.network = p2p.NewNetwork(n, n.peersDB, n.log)
+
+	return n, nil
+}
+
+// Start starts http and websocket servers, connects to other nodes
+// and receives blockchain from them. If no one node is known
+// node initializes new blockchain as root node
+func (n *Node) Start() error {
+	var err error
+
+	// api and p2p handlers are served by separate servers,
+	// so one of them can be exposed without the other,
+	// unless both are configured on the same address
+	shared := n.cfg.HTTPBind == n.cfg.WSBind && n.cfg.HTTPPort == n.cfg.WSPort
+
+	n.httpLn, err = net.Listen("tcp", net.JoinHostPort(n.cfg.HTTPBind, n.cfg.HTTPPort))
+	if err != nil {
+		return err
+	}
+	if shared {
+		n.wsLn = n.httpLn
+	} else {
+		n.wsLn, err = net.Listen("tcp", net.JoinHostPort(n.cfg.WSBind, n.cfg.WSPort))
+		if err != nil {
+			n.httpLn.Close()
+			return err
+		}
+	}
+
+	// origin node address
+	// needed for send other nodes
+	// that they know with which node to interact
+	addr := n.cfg.Addr
+	if addr == "" {
+		_, port, _ := net.SplitHostPort(n.wsLn.Addr().String())
+		addr = "ws://localhost:" + port + "/p2p"
+	}
+	n.network.SetAddr(addr)
+
+	apiMux := n.Handler()
+	p2pMux := apiMux
+	if !shared {
+		p2pMux = http.NewServeMux()
+	}
+	p2pMux.Handle("/p2p", n.network.Handler())
+
+	// start http server
+	n.httpServer = &http.Server{Handler: apiMux}
+	go n.serve(n.httpServer, n.httpLn, "http")
+
+	// start websocket server
+	if !shared {
+		n.wsServer = &http.Server{Handler: p2pMux}
+		go n.serve(n.wsServer, n.wsLn, "websocket")
+	}
+
+	// connect to other nodes
+	ok, err := n.bootstrap()
+	if !ok && err == nil && len(n.cfg.Seeds) != 0 {
+		err = errors.New("no one node is reachable")
+	}
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), n.cfg.ShutdownTimeout.Duration)
+		defer cancel()
+
+		n.Stop(ctx)
+		return err
+	}
+	if !ok {
+		// if no one node is known -> init root node
+		n.initRoot()
+	}
+
+	// stored blocks may be pruned by new config
+	n.prune()
+	// keep static nodes connected
+	go n.keepStatic()
+
+	return nil
+}
+
+// Stop stops accepting facts and mining solutions, waits for
+// in-flight mining, says goodbye to nodes, saves peers database
+// and shuts down servers. When context is done remaining
+// steps are made without waiting and context error is returned
+func (n *Node) Stop(ctx context.Context) error {
+	n.mu.Lock()
+	if n.stopping {
+		n.mu.Unlock()
+		return ErrStopped
+	}
+	n.stopping = true
+	n.mu.Unlock()
+
+	n.log.Println("Stop node")
+	close(n.done)
+
+	// end client subscriptions, so servers don't wait for them
+	n.events.Close()
+	// stop accepting clients and nodes before storage is closed
+	var errs []error
+	if n.httpServer != nil {
+		errs = append(errs, n.httpServer.Shutdown(ctx))
+	}
+	if n.wsServer != nil {
+		errs = append(errs, n.wsServer.Shutdown(ctx))
+	}
+
+	// finish in-flight mining
+	mined := make(chan struct{})
+	go func() {
+		n.mining.Wait()
+		close(mined)
+	}()
+	select {
+	case <-mined:
+	case <-ctx.Done():
+	}
+
+	// close nodes connections
+	n.network.Shutdown(ctx)
+
+	// flush storage
+	errs = append(errs, n.peersDB.Save(), n.webhooks.Close(), n.closeStore())
+	n.closeLog()
+
+	errs = append(errs, ctx.Err())
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checks that node is stopping
+func (n *Node) isStopping() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.stopping
+}
+
+// start tracking of new work, returns false if node is stopping
+func (n *Node) begin() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.stopping {
+		return false
+	}
+	n.mining.Add(1)
+	return true
+}
+
+// serve connections until server is shut down
+func (n *Node) serve(srv *http.Server, ln net.Listener, name string) {
+	n.log.Println("Start", name, "server on", ln.Addr())
+	err := srv.Serve(ln)
+	if err != nil && err != http.ErrServerClosed {
+		n.log.Println(name, "server stopped:", err)
+	}
+}
+
+// Handler returns handler of node http api, for example
+// to serve api by other server
+func (n *Node) Handler() *http.ServeMux {
+	mux := http.NewServeMux()
+	api.New(n, n.log).Register(mux)
+	return mux
+}
+
+// HTTPAddr returns address http server listens on
+func (n *Node) HTTPAddr() string {
+	return n.httpLn.Addr().String()
+}
+
+// WSAddr returns address websocket server listens on
+func (n *Node) WSAddr() string {
+	return n.wsLn.Addr().String()
+}
+
+// close log file if it is used
+func (n *Node) closeLog() {
+	if n.logFile != nil {
+		n.logFile.Close()
+	}
+}
+
+// close blocks storage if it is used
+func (n *Node) closeStore() error {
+	if n.store == nil {
+		return nil
+	}
+	return n.store.Close()
+}
+
+// init root node
+func (n *Node) initRoot() {
+	n.log.Println("Init root node")
+
+	if n.Height() < 0 {
+		// init blockchain with genesis block
+		_, err := n.chain.Replace([]*chain.Block{chain.Genesis()})
+		if err != nil {
+			n.log.Println("Failed to store genesis block:", err)
+		}
+	} else {
+		n.log.Println("Blockchain is loaded from data dir, height", n.Height())
+	}
+
+	// init mining block
+	blk := n.miner.Reset()
+	n.log.Println("Create new mining block", blk)
+	n.publishMining()
+}
+
+// returns mining complexity rules from config
+func (n *Node) difficulty() chain.Difficulty {
+	return chain.Difficulty{
+		BlockInterval: n.cfg.Difficulty.BlockInterval.Duration,
+		MinComplexity: n.cfg.Difficulty.MinComplexity,
+		MaxComplexity: n.cfg.Difficulty.MaxComplexity,
+	}
+}
+
+// Blocks returns all blockchain blocks
+func (n *Node) Blocks() []*chain.Block {
+	return n.chain.Blocks()
+}
+
+// Block returns block by index
+func (n *Node) Block(index int) (*chain.Block, bool) {
+	return n.chain.Block(index)
+}
+
+// BlockByHash returns block by its hash
+func (n *Node) BlockByHash(hash string) (*chain.Block, bool) {
+	return n.chain.BlockByHash(hash)
+}
+
+// BlocksRange returns at most limit blocks starting from index
+func (n *Node) BlocksRange(from, limit int) []*chain.Block {
+	return n.chain.Range(from, limit)
+}
+
+// HeadersRange returns at most limit block headers starting from index
+func (n *Node) HeadersRange(from, limit int) []*chain.Header {
+	return n.chain.Headers(from, limit)
+}
+
+// FactStatus returns fact by id and where it is:
+// confirmed in block, in mining block or in unconfirmed facts
+func (n *Node) FactStatus(id string) (*chain.Fact, *chain.FactStatus, bool) {
+	if fact, blk := n.chain.FindFact(id); fact != nil {
+		return fact, &chain.FactStatus{
+			Status:        chain.CONFIRMED,
+			BlockIndex:    &blk.Index,
+			Confirmations: n.Height() - blk.Index + 1,
+			Pruned:        blk.Index < n.chain.First(),
+		}, true
+	}
+
+	if blk := n.miner.Block(); blk != nil {
+		for _, fact := range blk.Facts {
+			if fact.Id == id {
+				return fact, &chain.FactStatus{Status: chain.MINING}, true
+			}
+		}
+	}
+
+	if fact, ok := n.pool.Get(id); ok {
+		return fact, &chain.FactStatus{Status: chain.PENDING}, true
+	}
+	return nil, nil, false
+}
+
+// QueryFacts returns confirmed facts matching query
+func (n *Node) QueryFacts(q chain.Query) []*chain.FoundFact {
+	return n.chain.Query(q)
+}
+
+// PendingFacts returns unconfirmed facts
+func (n *Node) PendingFacts() []*chain.Fact {
+	return n.pool.Facts()
+}
+
+// MempoolStats returns unconfirmed facts pool usage and limits
+func (n *Node) MempoolStats() mempool.Stats {
+	return n.pool.Stats()
+}
+
+// Height returns latest block index or -1
+// if blockchain is not received yet
+func (n *Node) Height() int {
+	return n.chain.Height()
+}
+
+// MiningBlock returns current mining block
+func (n *Node) MiningBlock() *chain.Block {
+	return n.miner.Block()
+}
+
+// MiningJob returns current mining block and its version
+func (n *Node) MiningJob() (*chain.Block, uint64) {
+	return n.miner.Job()
+}
+
+// SubmitFact adds new unconfirmed fact of registered type and
+// author and notifies nodes, returns error if fact doesn't match
+// schema of its type or doesn't fit in unconfirmed facts pool
+func (n *Node) SubmitFact(typ, author string, fact interface{}) (*chain.Fact, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopping {
+		return nil, ErrStopped
+	}
+
+	t := &chain.Fact{
+		Id:     chain.CalcHash(time.Now().String()),
+		Type:   typ,
+		Author: author,
+		Fact:   &fact,
+	}
+	err := n.schemas.Validate(t)
+	if err != nil {
+		return nil, err
+	}
+	n.log.Println("New fact notice", t.Id, typ, fact)
+
+	// append to other unconfirmed facts
+	err = n.addFact(t)
+	if err != nil {
+		return nil, err
+	}
+	n.refreshMining()
+	// notify nodes of a new fact
+	n.network.Broadcast(&p2p.Message{Type: p2p.FACT, Fact: t})
+
+	return t, nil
+}
+
+// check facts of received blocks against schemas of their types.
+// Facts of unknown types, untyped facts and facts without data of
+// older nodes are taken, so nodes with different schemas or type
+// requirement agree on blocks
+// mines the same block as other node if it can be mined after
+// our latest block, otherwise mines the next block ourselves
+func (n *Node) adoptMining(mining *chain.Block) {
+	if n.validMining(mining) {
+		n.miner.SetBlock(mining)
+	} else {
+		n.miner.Reset()
+	}
+}
+
+// checks mining block of other node, it has to follow our latest
+// block with complexity following difficulty and contain valid
+// facts which are not confirmed yet
+func (n *Node) validMining(mining *chain.Block) bool {
+	latest := n.chain.Latest()
+	if mining == nil || latest == nil ||
+		mining.Index != latest.Index+1 || mining.PrevHash != latest.Hash ||
+		mining.CalcHash() != mining.Hash ||
+		mining.Complexity != n.difficulty().Next(latest.Complexity, mining.Timestamp.Sub(latest.Timestamp)) {
+		return false
+	}
+	if max := n.cfg.Mining.MaxBlockFacts; max > 0 && len(mining.Facts) > max {
+		return false
+	}
+	if n.checkFacts([]*chain.Block{mining}) != nil {
+		return false
+	}
+
+	ids := make(map[string]bool)
+	for _, fact := range mining.Facts {
+		if ids[fact.Id] {
+			return false
+		}
+		ids[fact.Id] = true
+		if f, _ := n.chain.FindFact(fact.Id); f != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) checkFacts(blocks []*chain.Block) error {
+	for _, blk := range blocks {
+		for _, fact := range blk.Facts {
+			err := n.schemas.Validate(fact)
+			if _, ok := err.(*schema.ValidationError); ok {
+				return fmt.Errorf("fact %s of block %d: %v", fact.Id, blk.Index, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checks that fact is confirmed in blockchain or taken by mining block
+func (n *Node) confirmed(id string) bool {
+	if fact, _ := n.chain.FindFact(id); fact != nil {
+		return true
+	}
+	if blk := n.miner.Block(); blk != nil {
+		for _, fact := range blk.Facts {
+			if fact.Id == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// add fact to unconfirmed facts pool
+func (n *Node) addFact(fact *chain.Fact) error {
+	evicted, err := n.pool.Add(fact)
+	for _, t := range evicted {
+		n.log.Println("Evict fact", t.Id, "from full unconfirmed facts pool")
+	}
+	if err == nil {
+		n.events.Publish(&events.Event{Type: events.FACT, Fact: fact})
+	}
+	return err
+}
+
+// add new unconfirmed facts to mining block
+func (n *Node) refreshMining() {
+	if n.miner.Refresh() {
+		blk, job := n.miner.Job()
+		n.log.Println("Update mining block", blk, "job", job)
+		n.publishMining()
+	}
+}
+
+// notify clients of current mining block
+func (n *Node) publishMining() {
+	blk, job := n.miner.Job()
+	n.events.Publish(&events.Event{Type: events.MINING, Block: blk, Job: job})
+}
+
+// Webhooks returns webhooks of confirmed facts
+func (n *Node) Webhooks() *webhook.Dispatcher {
+	return n.webhooks
+}
+
+// Subscribe returns subscription to node events matching filter
+func (n *Node) Subscribe(f events.Filter) *events.Subscription {
+	return n.events.Subscribe(f)
+}
+
+// Schemas returns registered fact types
+func (n *Node) Schemas() []*schema.Entry {
+	return n.schemas.List()
+}
+
+// Schema returns registered fact type
+func (n *Node) Schema(typ string) (*schema.Entry, bool) {
+	return n.schemas.Get(typ)
+}
+
+// RegisterSchema registers fact type with schema and notifies nodes,
+// returns false if the same schema is already registered
+func (n *Node) RegisterSchema(typ string, data []byte) (*schema.Entry, bool, error) {
+	e, created, err := n.schemas.Register(typ, data)
+	if err != nil || !created {
+		return e, created, err
+	}
+	n.log.Println("Register fact type", typ, string(e.Schema))
+
+	n.network.Broadcast(&p2p.Message{Type: p2p.SCHEMAS, Schemas: []*schema.Entry{e}})
+	return e, true, nil
+}
+
+// MiningEnabled reports whether node accepts mining solutions
+func (n *Node) MiningEnabled() bool {
+	return n.cfg.Mining.Enabled
+}
+
+// Mine starts trying to solve mining block of job version with nonce
+// in background and notifies nodes on success, any version is accepted
+// if job is zero. Returns error if mining block has been replaced
+func (n *Node) Mine(nonce string, job uint64) error {
+	if _, cur := n.miner.Job(); job != 0 && job != cur {
+		n.metrics.miningAttempts.Inc(attemptStale)
+		return miner.ErrStaleJob
+	}
+	if !n.begin() {
+		return ErrStopped
+	}
+
+	go func() {
+		defer n.mining.Done()
+		n.log.Println("Try to solve task")
+
+		t, ok := n.miner.Try(nonce, job)
+		if !ok {
+			n.metrics.miningAttempts.Inc(attemptUnsolved)
+			return
+		}
+		n.metrics.miningAttempts.Inc(attemptSolved)
+		n.observeBlocks([]*chain.Block{t.ValidBlock})
+		n.log.Println("Task solved", nonce, "mining success notice", t)
+
+		// notify nodes
+		n.network.Broadcast(&p2p.Message{Type: p2p.VMBLOCKS, VMBlocks: t})
+		n.events.Publish(&events.Event{Type: events.BLOCK, Block: t.ValidBlock})
+		n.publishMining()
+		n.webhooks.Notify()
+		n.prune()
+		n.takeSnapshot()
+	}()
+	return nil
+}
+
+// Addr returns node websocket address for other nodes
+func (n *Node) Addr() string {
+	return n.network.Addr()
+}
+
+// Peers returns connected nodes
+func (n *Node) Peers() []*p2p.Peer {
+	return n.network.Peers()
+}
+
+// Peer returns connected node by id
+func (n *Node) Peer(id uint64) *p2p.Peer {
+	return n.network.Peer(id)
+}
+
+// Connect connects to node
+func (n *Node) Connect(addr string) (*p2p.Peer, error) {
+	return n.network.Connect(addr)
+}
+
+// HandleMessage handles blocks and facts received from nodes
+func (n *Node) HandleMessage(p *p2p.Peer, m *p2p.Message) {
+	if n.isStopping() {
+		// node doesn't change its state while it is stopping
+		return
+	}
+
+	switch m.Type {
+	case p2p.VMBLOCKS:
+		// if block
+		n.log.Println("From", p.Addr(), "node received VMBLOCKS", m.VMBlocks)
+		if n.Height() < 0 {
+			// blockchain is not received yet
+			return
+		}
+		if m.VMBlocks == nil || m.VMBlocks.ValidBlock == nil {
+			p.Misbehave(10, "empty block")
+			return
+		}
+
+		if m.VMBlocks.ValidBlock.Index > n.Height()+1 {
+			// blocks are missed, for example node
+			// is started with outdated stored blockchain
+			n.requestBlocks(p, n.Height()+1)
+			return
+		}
+		if m.VMBlocks.ValidBlock.Index <= n.Height() {
+			// block is already received or the node announces its
+			// fork, fork is taken only when it has more work
+			n.log.Println("Block", m.VMBlocks.ValidBlock.Index, "of", p.Addr(), "node is not newer than ours")
+			return
+		}
+		if m.VMBlocks.ValidBlock.PrevHash != n.chain.Latest().Hash {
+			// node mined block on its fork of blockchain,
+			// request its blocks to choose fork by work
+			if chain.Invalid([]*chain.Block{m.VMBlocks.ValidBlock}, n.difficulty()) != "" {
+				n.rejectBlocks([]*chain.Block{m.VMBlocks.ValidBlock})
+				p.Misbehave(10, "invalid block")
+				return
+			}
+			n.requestBlocks(p, m.VMBlocks.ValidBlock.Index)
+			return
+		}
+
+		err := n.checkFacts([]*chain.Block{m.VMBlocks.ValidBlock})
+		if err != nil {
+			n.metrics.invalidBlocks.Inc(invalidFact)
+			p.Misbehave(10, "invalid block: "+err.Error())
+			return
+		}
+
+		// valid this block and append to blockchain
+		latest := n.chain.Latest()
+		err = n.chain.Append(m.VMBlocks.ValidBlock)
+		if err == chain.ErrInvalidBlock {
+			n.rejectBlocks([]*chain.Block{latest, m.VMBlocks.ValidBlock})
+			p.Misbehave(10, "invalid block")
+			return
+		}
+		if err != nil {
+			n.log.Println("Failed to store block:", err)
+			return
+		}
+		n.observeBlocks([]*chain.Block{m.VMBlocks.ValidBlock})
+		p.SetHeight(m.VMBlocks.ValidBlock.Index)
+		n.events.Publish(&events.Event{Type: events.BLOCK, Block: m.VMBlocks.ValidBlock})
+		n.webhooks.Notify()
+
+		// remove confirmed facts
+		n.pool.Remove(m.VMBlocks.ValidBlock)
+		// update mining block, node may send block without
+		// its mining block or with invalid one, then mine
+		// the next block ourselves
+		n.adoptMining(m.VMBlocks.MiningBlock)
+		n.publishMining()
+		n.refreshMining()
+		n.prune()
+		n.takeSnapshot()
+	case p2p.FACT:
+		// if fact
+		if m.Fact == nil || m.Fact.Fact == nil && m.Fact.Blob == "" {
+			p.Misbehave(10, "empty fact")
+			return
+		}
+		if m.Fact.Blob != "" && (m.Fact.Fact != nil || !blob.IsHash(m.Fact.Blob)) {
+			p.Misbehave(10, "invalid blob fact")
+			return
+		}
+		if m.Fact.Blob != "" {
+			n.log.Println("From", p.Addr(), "node received new fact", m.Fact.Id, m.Fact.Type, "blob", m.Fact.Blob)
+		} else {
+			n.log.Println("From", p.Addr(), "node received new fact", m.Fact.Id, m.Fact.Type, *m.Fact.Fact)
+		}
+
+		err := n.schemas.Validate(m.Fact)
+		if err == schema.ErrUnknownType || err == schema.ErrTypeRequired {
+			// node may not know the type yet or accepts untyped facts
+			n.log.Println("Fact", m.Fact.Id, "from", p.Addr(), "node is not taken:", err)
+			return
+		}
+		if err != nil {
+			p.Misbehave(10, "invalid fact: "+err.Error())
+			return
+		}
+
+		// append to unconfirmed facts
+		err = n.addFact(m.Fact)
+		if err != nil {
+			n.log.Println("Fact", m.Fact.Id, "from", p.Addr(), "node is not taken:", err)
+			return
+		}
+		n.refreshMining()
+	case p2p.GETBLOCKS:
+		// if blockchain request -> send blocks starting
+		// from requested one and mining block
+		if n.Height() < 0 || m.From < 0 {
+			return
+		}
+		// fact types first, so facts of them are accepted
+		if schemas := n.schemas.List(); len(schemas) != 0 {
+			p.Send(&p2p.Message{Type: p2p.SCHEMAS, Schemas: schemas})
+		}
+		// blocks are sent in pages fitting in one message,
+		// mining block is sent with the last one
+		blocks, next := n.blocksPage(m.From)
+		msg := &p2p.Message{Type: p2p.BLOCKS, Blockchain: blocks, Next: next}
+		if next == 0 {
+			msg.VMBlocks = &chain.VMBlocks{MiningBlock: n.miner.Block()}
+		}
+		p.Send(msg)
+	case p2p.BLOCKS:
+		// if blockchain -> replace ours if it is longer
+		if !n.answered(p) {
+			p.Misbehave(10, "blockchain is not requested")
+			return
+		}
+		blocks, ok := n.addPage(p, m.Blockchain, m.Next)
+		if !ok {
+			p.Misbehave(10, "blockchain page with wrong next block")
+			return
+		}
+		if m.Next != 0 {
+			n.requestBlocks(p, m.Next)
+			return
+		}
+		if m.VMBlocks == nil {
+			p.Misbehave(10, "blockchain without mining block")
+			return
+		}
+		n.syncBlockchain(p, blocks, m.VMBlocks.MiningBlock)
+	case p2p.GETBLOB, p2p.BLOB:
+		// if fact data kept off-chain
+		n.handleBlob(p, m)
+	case p2p.SCHEMAS:
+		// if fact types -> register unknown ones
+		for _, e := range m.Schemas {
+			if e == nil {
+				continue
+			}
+			_, created, err := n.schemas.Register(e.Type, e.Schema)
+			if err != nil {
+				n.log.Println("Fact type", e.Type, "from", p.Addr(), "node is not registered:", err)
+				continue
+			}
+			if created {
+				n.log.Println("From", p.Addr(), "node received fact type", e.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+	"github.com/lavrs/blkchn/config"
+	"github.com/lavrs/blkchn/mempool"
+	"github.com/lavrs/blkchn/p2p"
+	"github.com/lavrs/blkchn/store"
+)
+
+// returns config of node on free local ports,
+// blocks of chaintest follow its difficulty
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.HTTPBind, cfg.WSBind = "127.0.0.1", "127.0.0.1"
+	cfg.HTTPPort, cfg.WSPort = "0", "0"
+	cfg.Difficulty.MinComplexity = chaintest.Complexity
+	cfg.Difficulty.MaxComplexity = chaintest.Complexity
+	return cfg
+}
+
+// writes blocks to data dir the same way as chain import
+func importBlocks(t *testing.T, dir string, blocks []*chain.Block) {
+	var buf bytes.Buffer
+	w, err := store.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blk := range blocks {
+		err = w.Write(blk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Import(dir, &buf, chaintest.Difficulty, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// starts node configured with cfg with blocks stored
+// in its data dir, returns function stopping it
+func startNode(t *testing.T, cfg *config.Config, blocks []*chain.Block) (*Node, func()) {
+	dir, err := ioutil.TempDir("", "node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DataDir = dir
+	if len(blocks) != 0 {
+		importBlocks(t, dir, blocks)
+	}
+
+	n, err := New(cfg)
+	if err == nil {
+		err = n.Start()
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return n, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		n.Stop(ctx)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSyncPages(t *testing.T) {
+	// more blocks than fit in one message
+	blocks := chaintest.NewChain(pageBlocks*2 + 10)
+	seed, stop := startNode(t, testConfig(), blocks)
+	defer stop()
+
+	page, next := seed.blocksPage(0)
+	if len(page) != pageBlocks || next != pageBlocks {
+		t.Fatalf("want page of %d blocks followed by %d, got %d blocks followed by %d", pageBlocks, pageBlocks, len(page), next)
+	}
+	page, next = seed.blocksPage(pageBlocks * 2)
+	if len(page) != 10 || next != 0 {
+		t.Fatalf("want the last page of 10 blocks, got %d blocks followed by %d", len(page), next)
+	}
+
+	cfg := testConfig()
+	cfg.Seeds = []string{seed.HTTPAddr()}
+	n, stop := startNode(t, cfg, nil)
+	defer stop()
+
+	if n.Height() != seed.Height() || n.chain.Latest().Hash != seed.chain.Latest().Hash {
+		t.Fatalf("want blockchain of height %d received, got %d", seed.Height(), n.Height())
+	}
+}
+
+func TestValidMining(t *testing.T) {
+	blocks := chaintest.NewChain(3)
+	n, stop := startNode(t, testConfig(), blocks)
+	defer stop()
+
+	var data interface{} = "data"
+	latest := blocks[len(blocks)-1]
+	tests := []struct {
+		name   string
+		mining func() *chain.Block
+		valid  bool
+	}{
+		{
+			name: "next block",
+			mining: func() *chain.Block {
+				return chain.NextBlock(latest, []*chain.Fact{{Id: "new", Fact: &data}}, chaintest.Difficulty)
+			},
+			valid: true,
+		},
+		{
+			name:   "no block",
+			mining: func() *chain.Block { return nil },
+		},
+		{
+			name:   "after other block",
+			mining: func() *chain.Block { return chain.NextBlock(blocks[1], nil, chaintest.Difficulty) },
+		},
+		{
+			name: "wrong complexity",
+			mining: func() *chain.Block {
+				return chain.NextBlock(latest, nil, chain.Difficulty{MinComplexity: chaintest.Complexity + 1})
+			},
+		},
+		{
+			name: "wrong hash",
+			mining: func() *chain.Block {
+				blk := chain.NextBlock(latest, nil, chaintest.Difficulty)
+				blk.Facts = []*chain.Fact{{Id: "new", Fact: &data}}
+				return blk
+			},
+		},
+		{
+			name: "confirmed fact",
+			mining: func() *chain.Block {
+				return chain.NextBlock(latest, []*chain.Fact{{Id: "1", Fact: &data}}, chaintest.Difficulty)
+			},
+		},
+		{
+			name: "duplicated fact",
+			mining: func() *chain.Block {
+				return chain.NextBlock(latest, []*chain.Fact{{Id: "new", Fact: &data}, {Id: "new", Fact: &data}}, chaintest.Difficulty)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if n.validMining(tt.mining()) != tt.valid {
+				t.Fatalf("want valid %v", tt.valid)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	n, stop := startNode(t, testConfig(), chaintest.NewChain(2))
+	addr := n.WSAddr()
+	stop()
+
+	_, err := net.Dial("tcp", addr)
+	if err == nil {
+		t.Fatalf("want connections refused after stop")
+	}
+
+	var data interface{} = "data"
+	n.HandleMessage(nil, &p2p.Message{Type: p2p.FACT, Fact: &chain.Fact{Id: "new", Fact: &data}})
+	if n.pool.Len() != 0 {
+		t.Fatalf("want fact refused after stop")
+	}
+}
+
+func TestAddConfirmedFact(t *testing.T) {
+	blocks := chaintest.NewChain(3)
+	n, stop := startNode(t, testConfig(), blocks)
+	defer stop()
+
+	var data interface{} = "data"
+	n.miner.SetBlock(chain.NextBlock(blocks[len(blocks)-1], []*chain.Fact{{Id: "mining", Fact: &data}}, chaintest.Difficulty))
+
+	for _, id := range []string{"1", "mining"} {
+		err := n.addFact(&chain.Fact{Id: id, Fact: &data})
+		if err != mempool.ErrConfirmed {
+			t.Fatalf("want fact %s refused with %v, got %v", id, mempool.ErrConfirmed, err)
+		}
+	}
+	err := n.addFact(&chain.Fact{Id: "new", Fact: &data})
+	if err != nil {
+		t.Fatalf("want new fact added, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package node
+
+// min count of blocks pruned at once, store
+// rewrites its blocks on each pruning
+const pruneBatch = 10
+
+// discard facts of old blocks keeping their headers
+// if pruning is configured
+func (n *Node) prune() {
+	p := n.cfg.Pruning
+	if p.KeepBlocks == 0 && p.MaxBytes == 0 {
+		return
+	}
+
+	pruned, err := n.chain.Prune(p.KeepBlocks, p.MaxBytes, pruneBatch)
+	if err != nil {
+		n.log.Println("Failed to store pruned blockchain:", err)
+		return
+	}
+	if pruned != 0 {
+		n.log.Println("Prune facts of", pruned, "blocks, first block with facts", n.chain.First())
+	}
+}
+
+// First returns index of the first block kept with facts,
+// zero for full node
+func (n *Node) First() int {
+	return n.chain.First()
+}
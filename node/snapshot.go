@@ -0,0 +1,228 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lavrs/blkchn/api"
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/snapshot"
+)
+
+const (
+	// count of block headers requested at once
+	headersPage = 1000
+	// count of the latest blocks which proof of work
+	// is checked before snapshot is taken
+	verifyBlocks = 100
+)
+
+// Snapshot returns the latest node state snapshot, nil if there is no one
+func (n *Node) Snapshot() *snapshot.Snapshot {
+	n.snapMu.Lock()
+	defer n.snapMu.Unlock()
+
+	return n.snapshot
+}
+
+// take snapshot if interval blocks are added since the latest
+// one or blockchain doesn't have its tip block anymore
+func (n *Node) takeSnapshot() {
+	interval := n.cfg.Snapshots.Interval
+	if interval == 0 {
+		return
+	}
+
+	n.snapMu.Lock()
+	defer n.snapMu.Unlock()
+
+	last := 0
+	if n.snapshot != nil {
+		last = n.snapshot.Tip.Index
+		headers := n.chain.Headers(last, 1)
+		if len(headers) == 0 || headers[0].Hash != n.snapshot.Tip.Hash {
+			// snapshot tip is replaced by reorg
+			last = 0
+		}
+	}
+	if n.Height() < last+interval {
+		return
+	}
+
+	tip, facts := n.chain.FactIndex()
+	s := snapshot.New(tip, facts, n.pool.Facts())
+	if n.cfg.DataDir != "" {
+		err := s.Save(n.cfg.DataDir)
+		if err != nil {
+			n.log.Println("Failed to save snapshot:", err)
+		}
+	}
+	n.snapshot = s
+	n.log.Println("Take snapshot at block", tip.Index, "hash", s.Hash)
+}
+
+// start empty blockchain from snapshot of one of seed nodes,
+// returns false if no one seed node has valid snapshot
+func (n *Node) restoreSnapshot() bool {
+	for _, seed := range n.cfg.Seeds {
+		err := n.restoreFrom(seed)
+		if err != nil {
+			n.log.Println("Failed to restore snapshot from", seed, "seed:", err)
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// download snapshot of seed node, check it against block headers
+// and the latest blocks and start blockchain from its tip block.
+// Snapshot tip has to be confirmed by another seed node or
+// blocks from checkpoint block to the tip are checked
+func (n *Node) restoreFrom(seed string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncWait)
+	defer cancel()
+
+	s := &snapshot.Snapshot{}
+	err := get(ctx, "http://"+seed+"/snapshot/latest", s)
+	if err != nil {
+		return err
+	}
+	if s.Tip == nil {
+		return errors.New("snapshot has no tip")
+	}
+
+	// headers from genesis to snapshot tip
+	var headers []*chain.Header
+	for len(headers) <= s.Tip.Index {
+		t := &api.BlocksResponse{}
+		err = get(ctx, fmt.Sprintf("http://%s/v1/blocks?headers=true&from=%d&limit=%d",
+			seed, len(headers), headersPage), t)
+		if err != nil {
+			return err
+		}
+		if len(t.Headers) == 0 {
+			break
+		}
+		headers = append(headers, t.Headers...)
+	}
+	if len(headers) > s.Tip.Index+1 {
+		headers = headers[:s.Tip.Index+1]
+	}
+
+	// blocks which proof of work is checked
+	from := s.Tip.Index - verifyBlocks + 1
+	checkpoint := -1
+	if cp := n.cfg.Snapshots.Checkpoint; cp != "" {
+		for _, h := range headers {
+			if h.Hash == cp {
+				checkpoint = h.Index
+			}
+		}
+		if checkpoint < 0 {
+			return errors.New("checkpoint block is not in headers of snapshot")
+		}
+		if checkpoint < from {
+			from = checkpoint
+		}
+	}
+	if from < 0 {
+		from = 0
+	}
+	var blocks []*chain.Block
+	for next := from; next <= s.Tip.Index; {
+		t := &api.BlocksResponse{}
+		err = get(ctx, fmt.Sprintf("http://%s/v1/blocks?from=%d&limit=%d", seed, next, headersPage), t)
+		if err != nil {
+			return err
+		}
+		if len(t.Blocks) == 0 {
+			break
+		}
+		blocks = append(blocks, t.Blocks...)
+		next = t.Blocks[len(t.Blocks)-1].Index + 1
+	}
+	for len(blocks) != 0 && blocks[len(blocks)-1].Index > s.Tip.Index {
+		blocks = blocks[:len(blocks)-1]
+	}
+	if checkpoint >= 0 && (len(blocks) == 0 || blocks[0].Index > checkpoint) {
+		// pruned seed may not have blocks from checkpoint
+		return errors.New("seed doesn't have blocks from checkpoint block")
+	}
+
+	base, err := s.Verify(headers, blocks)
+	if err != nil {
+		return err
+	}
+	if checkpoint < 0 {
+		err = n.confirmTip(ctx, seed, s.Tip)
+		if err != nil {
+			return err
+		}
+	}
+	tip := blocks[len(blocks)-1]
+	err = n.chain.Restore(base, []*chain.Block{tip})
+	if err != nil {
+		return err
+	}
+	n.log.Println("Blockchain restored from", seed, "seed snapshot at block", tip.Index, "hash", s.Hash)
+
+	// unconfirmed facts of snapshot are taken if they fit in pool
+	for _, fact := range s.Mempool {
+		if f, _ := n.chain.FindFact(fact.Id); f == nil && (fact.Fact != nil || fact.Blob != "") {
+			n.addFact(fact)
+		}
+	}
+
+	n.snapMu.Lock()
+	n.snapshot = s
+	n.snapMu.Unlock()
+	if n.cfg.DataDir != "" {
+		err = s.Save(n.cfg.DataDir)
+		if err != nil {
+			n.log.Println("Failed to save snapshot:", err)
+		}
+	}
+	return nil
+}
+
+// check that another seed node has snapshot tip block,
+// so single seed can't make up blockchain
+func (n *Node) confirmTip(ctx context.Context, seed string, tip *chain.Header) error {
+	for _, other := range n.cfg.Seeds {
+		if other == seed {
+			continue
+		}
+		t := &api.BlocksResponse{}
+		err := get(ctx, fmt.Sprintf("http://%s/v1/blocks?headers=true&from=%d&limit=1", other, tip.Index), t)
+		if err != nil {
+			n.log.Println("Failed to confirm snapshot tip by", other, "seed:", err)
+			continue
+		}
+		if len(t.Headers) != 0 && t.Headers[0].Index == tip.Index && t.Headers[0].Hash == tip.Hash {
+			return nil
+		}
+	}
+	return errors.New("snapshot tip is not confirmed by other seeds or checkpoint")
+}
+
+// request url and decode json response into v
+func get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	r, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, r.Status)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
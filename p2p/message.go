@@ -0,0 +1,104 @@
+// Package p2p implements communication between nodes over websockets.
+package p2p
+
+import (
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/schema"
+)
+
+// ProtocolVersion is version of the nodes communication protocol
+const ProtocolVersion = 1
+
+const (
+	// constants are used to understand
+	// what data came from the node
+
+	// VMBLOCKS means that received valid / mining block
+	VMBLOCKS = iota
+	// FACT means that received new fact
+	FACT
+	// PING means that node checks the connection
+	PING
+	// PONG means that received response to the ping
+	PONG
+	// VERSION means that received node handshake
+	VERSION
+	// GETBLOCKS means that node requests blockchain
+	GETBLOCKS
+	// BLOCKS means that received blockchain and mining block
+	BLOCKS
+	// GOODBYE means that node is going to disconnect
+	GOODBYE
+	// SCHEMAS means that received fact types with their schemas
+	SCHEMAS
+	// GETBLOB means that node requests fact data kept off-chain
+	GETBLOB
+	// BLOB means that received fact data kept off-chain,
+	// data is absent if node doesn't have it
+	BLOB
+)
+
+// names of message types
+var typeNames = map[int]string{
+	VMBLOCKS:  "vmblocks",
+	FACT:      "fact",
+	PING:      "ping",
+	PONG:      "pong",
+	VERSION:   "version",
+	GETBLOCKS: "getblocks",
+	BLOCKS:    "blocks",
+	GOODBYE:   "goodbye",
+	SCHEMAS:   "schemas",
+	GETBLOB:   "getblob",
+	BLOB:      "blob",
+}
+
+// TypeName returns name of message type, unknown for unknown type
+func TypeName(typ int) string {
+	name, ok := typeNames[typ]
+	if !ok {
+		return "unknown"
+	}
+	return name
+}
+
+// Version type for handshake between nodes
+type Version struct {
+	// protocol version
+	Protocol int `json:"protocol"`
+	// latest block index
+	Height int `json:"height"`
+	// address other nodes can connect to
+	Addr string `json:"addr"`
+	// node doesn't have facts of blocks before the first block,
+	// it is pruned or started from snapshot
+	Pruned     bool `json:"pruned,omitempty"`
+	FirstBlock int  `json:"first_block,omitempty"`
+}
+
+// Message type for communicate with other nodes
+type Message struct {
+	// information type
+	Type     int             `json:"type,omitempty"`
+	Fact     *chain.Fact     `json:"fact,omitempty"`
+	VMBlocks *chain.VMBlocks `json:"vm_blocks,omitempty"`
+	// ping send time in unix nanoseconds
+	// used only with PING / PONG type
+	Ping int64 `json:"ping,omitempty"`
+	// node handshake
+	// used only with VERSION type
+	Version *Version `json:"version,omitempty"`
+	// index of the first requested block, node
+	// has blocks before it, used only with GETBLOCKS type
+	From       int            `json:"from,omitempty"`
+	Blockchain []*chain.Block `json:"blockchain,omitempty"`
+	// index of block the next page of blockchain starts from,
+	// zero for the last page, used only with BLOCKS type
+	Next int `json:"next,omitempty"`
+	// used only with SCHEMAS type
+	Schemas []*schema.Entry `json:"schemas,omitempty"`
+	// blob hash and data
+	// used only with GETBLOB / BLOB type
+	Hash string `json:"hash,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
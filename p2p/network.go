@@ -0,0 +1,268 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lavrs/blkchn/metrics"
+	"golang.org/x/net/websocket"
+)
+
+// ErrClosed means that network is shut down and doesn't accept nodes
+var ErrClosed = errors.New("network is shut down")
+
+// Handler type for handle messages received from nodes
+type Handler interface {
+	// Height returns latest block index
+	// or -1 if blockchain is not received yet
+	Height() int
+	// First returns index of the first block kept
+	// with facts, zero if node has all blocks
+	First() int
+	// HandleMessage handles message that is not
+	// a part of connection management
+	HandleMessage(p *Peer, m *Message)
+}
+
+// Network type for store current connections
+type Network struct {
+	handler Handler
+	db      *PeersDB
+	log     *log.Logger
+
+	mu sync.RWMutex
+	// address advertised to other nodes
+	addr string
+	// store to send data to the nodes
+	peers []*Peer
+	// last assigned peer id
+	lastId uint64
+	// set when network is shut down
+	closed bool
+
+	// messages sent to and received from nodes by type
+	sent *metrics.Counter
+	recv *metrics.Counter
+}
+
+// NewNetwork returns network without connections, messages are
+// passed to handler and nodes addresses are remembered in db
+func NewNetwork(h Handler, db *PeersDB, logger *log.Logger) *Network {
+	names := make([]string, 0, len(typeNames))
+	for _, name := range typeNames {
+		names = append(names, name)
+	}
+	return &Network{
+		handler: h,
+		db:      db,
+		log:     logger,
+		sent:    metrics.NewCounter(names...),
+		recv:    metrics.NewCounter(names...),
+	}
+}
+
+// Messages returns counters of messages sent to
+// and received from nodes by message type
+func (n *Network) Messages() (sent, recv *metrics.Counter) {
+	return n.sent, n.recv
+}
+
+// Addr returns address of this node for other nodes
+func (n *Network) Addr() string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.addr
+}
+
+// SetAddr sets address of this node for other nodes
+func (n *Network) SetAddr(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.addr = addr
+}
+
+// Connect dials to node and adds it to connections
+func (n *Network) Connect(addr string) (*Peer, error) {
+	ws, err := websocket.Dial(addr, "", n.Addr())
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPeer(n, ws, atomic.AddUint64(&n.lastId, 1), OUTBOUND)
+	// added to connections
+	if !n.add(p) {
+		p.Close()
+		return nil, ErrClosed
+	}
+	// start receiving node
+	go n.receive(p)
+
+	return p, nil
+}
+
+// Handler returns websocket handler accepting new nodes
+func (n *Network) Handler() websocket.Handler {
+	return func(ws *websocket.Conn) {
+		p := newPeer(n, ws, atomic.AddUint64(&n.lastId, 1), INBOUND)
+		// add node to connections
+		if !n.add(p) {
+			p.Close()
+			return
+		}
+
+		// start receiving data from node
+		n.receive(p)
+	}
+}
+
+// Peers returns connected peers
+func (n *Network) Peers() []*Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return append([]*Peer(nil), n.peers...)
+}
+
+// Peer returns connected peer by id
+func (n *Network) Peer(id uint64) *Peer {
+	for _, p := range n.Peers() {
+		if p.Id() == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// IsConnected checks that node with address is connected
+func (n *Network) IsConnected(addr string) bool {
+	for _, p := range n.Peers() {
+		pInfo := p.Info()
+		if pInfo.Addr == addr || pInfo.RemoteAddr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Broadcast sends message to each node
+func (n *Network) Broadcast(m *Message) {
+	for _, p := range n.Peers() {
+		p.Send(m)
+	}
+}
+
+// Close disconnects all nodes
+func (n *Network) Close() {
+	for _, p := range n.Peers() {
+		p.Close()
+	}
+}
+
+// Shutdown says goodbye to all nodes and disconnects them,
+// nodes that have not received goodbye until context is done
+// are disconnected immediately, new nodes are not accepted after it
+func (n *Network) Shutdown(ctx context.Context) {
+	n.mu.Lock()
+	n.closed = true
+	n.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range n.Peers() {
+		wg.Add(1)
+		go func(p *Peer) {
+			defer wg.Done()
+			p.Disconnect(ctx)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// add peer to connections, returns false if network is shut down
+func (n *Network) add(p *Peer) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return false
+	}
+	n.peers = append(n.peers, p)
+	return true
+}
+
+// remove node from connections
+func (n *Network) remove(p *Peer) {
+	n.log.Println(p.Addr(), "node disconnect")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// search node id
+	for i, peer := range n.peers {
+		// if found
+		if peer == p {
+			// remove from store
+			n.peers = append(n.peers[:i], n.peers[i+1:]...)
+			return
+		}
+	}
+}
+
+// receive data from node
+func (n *Network) receive(p *Peer) {
+	n.log.Println("Start receive data from", p.Addr(), "node")
+	for {
+		m := &Message{}
+
+		err := p.Receive(m)
+		switch err.(type) {
+		case *json.SyntaxError, *json.UnmarshalTypeError:
+			// if malformed message -> punish node
+			p.Misbehave(10, "malformed message")
+			continue
+		}
+		if err != nil {
+			// if error -> node disconnect
+			p.Close()
+			return
+		}
+		n.recv.Inc(TypeName(m.Type))
+
+		// switch data type
+		switch m.Type {
+		case PING:
+			// if ping -> respond with the same time
+			p.Send(&Message{Type: PONG, Ping: m.Ping})
+		case PONG:
+			// if pong -> update node latency
+			p.pong(m.Ping)
+		case VERSION:
+			// if handshake -> remember node info
+			n.log.Println("From", p.Addr(), "node received version", m.Version)
+			if m.Version == nil {
+				p.Misbehave(10, "empty version")
+				continue
+			}
+			p.version(m.Version)
+
+			if m.Version.Addr != n.Addr() {
+				err = n.db.Seen(m.Version.Addr)
+				if err != nil {
+					n.log.Println("Failed to save peers database:", err)
+				}
+			}
+		case GOODBYE:
+			// if goodbye -> node is leaving
+			n.log.Println(p.Addr(), "node said goodbye")
+			p.Close()
+			return
+		default:
+			n.handler.HandleMessage(p, m)
+		}
+	}
+}
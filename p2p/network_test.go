@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// handler passing messages to channel
+type testHandler struct {
+	height   int
+	messages chan *Message
+}
+
+func (h *testHandler) Height() int { return h.height }
+
+func (h *testHandler) First() int { return 0 }
+
+func (h *testHandler) HandleMessage(p *Peer, m *Message) { h.messages <- m }
+
+// returns network with handler of blockchain of height
+func newNetwork(t *testing.T, height int) (*Network, *testHandler) {
+	db, err := LoadPeersDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &testHandler{height: height, messages: make(chan *Message, 16)}
+	return NewNetwork(h, db, log.New(ioutil.Discard, "", 0)), h
+}
+
+// returns server network, client network and client peer connected to server
+func connect(t *testing.T) (*Network, *testHandler, *Network, *Peer, func()) {
+	server, h := newNetwork(t, 5)
+	srv := httptest.NewServer(server.Handler())
+	server.SetAddr("ws" + strings.TrimPrefix(srv.URL, "http"))
+
+	client, _ := newNetwork(t, 3)
+	client.SetAddr("ws://localhost:1/p2p")
+	p, err := client.Connect(server.Addr())
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return server, h, client, p, func() {
+		client.Close()
+		server.Close()
+		srv.Close()
+	}
+}
+
+// waits until condition is true
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("want %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	server, _, client, p, stop := connect(t)
+	defer stop()
+
+	waitFor(t, "handshake of server received", func() bool { return p.Info().Version == ProtocolVersion })
+	if info := p.Info(); info.Height != 5 || info.Addr != server.Addr() {
+		t.Fatalf("want server height 5 and address %s, got %d and %s", server.Addr(), info.Height, info.Addr)
+	}
+	waitFor(t, "client connected to server", func() bool {
+		peers := server.Peers()
+		return len(peers) == 1 && peers[0].Info().Version == ProtocolVersion && peers[0].Addr() == client.Addr()
+	})
+}
+
+func TestMessages(t *testing.T) {
+	_, h, _, p, stop := connect(t)
+	defer stop()
+
+	p.Send(&Message{Type: FACT, Fact: &chain.Fact{Id: "a"}})
+	select {
+	case m := <-h.messages:
+		if m.Type != FACT || m.Fact == nil || m.Fact.Id != "a" {
+			t.Fatalf("want fact a, got %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want message handled by server")
+	}
+
+	// connection messages are not passed to handler
+	p.Send(&Message{Type: PING, Ping: time.Now().UnixNano()})
+	waitFor(t, "pong received", func() bool { return p.Info().Latency > 0 })
+	if len(h.messages) != 0 {
+		t.Fatalf("want ping handled by network")
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	_, _, client, p, stop := connect(t)
+	defer stop()
+
+	// peer without writer doesn't send queued messages
+	blocked := &Peer{Conn: p.Conn, network: client, send: make(chan *Message, sendQueueSize), done: make(chan struct{})}
+	for i := 0; i < sendQueueSize+maxDropped; i++ {
+		blocked.Send(&Message{Type: PING})
+	}
+	select {
+	case <-blocked.done:
+	default:
+		t.Fatalf("want peer disconnected after %d dropped messages", maxDropped)
+	}
+}
+
+func TestMisbehave(t *testing.T) {
+	_, _, _, p, stop := connect(t)
+	defer stop()
+
+	p.Misbehave(maxBanScore-1, "test")
+	if p.Info().BanScore != maxBanScore-1 {
+		t.Fatalf("want ban score %d, got %d", maxBanScore-1, p.Info().BanScore)
+	}
+	p.Misbehave(1, "test")
+	select {
+	case <-p.done:
+	default:
+		t.Fatalf("want peer disconnected at ban score %d", maxBanScore)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	server, _, client, p, stop := connect(t)
+	defer stop()
+	waitFor(t, "client connected to server", func() bool { return len(server.Peers()) == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	waitFor(t, "client disconnected after goodbye", func() bool {
+		select {
+		case <-p.done:
+			return true
+		default:
+			return false
+		}
+	})
+
+	// network doesn't accept nodes after shutdown
+	_, err := client.Connect(server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(server.Peers()) != 0 {
+		t.Fatalf("want no nodes accepted after shutdown, got %d", len(server.Peers()))
+	}
+}
@@ -0,0 +1,318 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// size of the peer outbound message queue
+	sendQueueSize = 64
+	// time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+	// number of messages in a row that can be dropped
+	// because of a full queue before the peer is disconnected
+	maxDropped = 16
+	// period of sending pings to the peer
+	pingPeriod = 15 * time.Second
+	// number of pings in a row left without pong
+	// before the peer is considered dead
+	maxMissedPings = 3
+	// time allowed to read the next message from the peer
+	readWait = pingPeriod * (maxMissedPings + 1)
+	// ban score after which the peer is disconnected
+	maxBanScore = 100
+)
+
+const (
+	// INBOUND means that the peer connected to this node
+	INBOUND = "inbound"
+	// OUTBOUND means that this node connected to the peer
+	OUTBOUND = "outbound"
+)
+
+// PeerInfo type for send peer state to clients
+type PeerInfo struct {
+	Id uint64 `json:"id"`
+	// inbound or outbound
+	Direction string `json:"direction"`
+	// address of the connection
+	RemoteAddr string `json:"remote_addr"`
+	// address advertised by the peer
+	Addr        string    `json:"addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	BytesSent   uint64    `json:"bytes_sent"`
+	BytesRecv   uint64    `json:"bytes_recv"`
+	MsgsSent    uint64    `json:"msgs_sent"`
+	MsgsRecv    uint64    `json:"msgs_recv"`
+	// best known peer block index
+	Height int `json:"height"`
+	// peer protocol version
+	// 0 until handshake is received
+	Version int `json:"version"`
+	// peer doesn't have facts of blocks before the first block
+	Pruned     bool `json:"pruned"`
+	FirstBlock int  `json:"first_block"`
+	BanScore   int  `json:"ban_score"`
+	// round trip time of the latest ping
+	Latency time.Duration `json:"latency"`
+}
+
+// Peer type for store node connection
+type Peer struct {
+	// websocket connection
+	Conn *websocket.Conn
+
+	network *Network
+	// outbound messages queue
+	send chan *Message
+	// closed when peer disconnects
+	done chan struct{}
+	once sync.Once
+
+	mu    sync.Mutex
+	state PeerInfo
+	// count of messages dropped in a row
+	dropped int
+	// count of pings left without pong
+	missedPings int
+}
+
+// create peer, start its writer and send handshake
+func newPeer(n *Network, ws *websocket.Conn, id uint64, direction string) *Peer {
+	p := &Peer{
+		Conn:    ws,
+		network: n,
+		send:    make(chan *Message, sendQueueSize),
+		done:    make(chan struct{}),
+		state: PeerInfo{
+			Id:          id,
+			Direction:   direction,
+			Addr:        ws.RemoteAddr().String(),
+			RemoteAddr:  ws.RemoteAddr().String(),
+			ConnectedAt: time.Now(),
+		},
+	}
+	if ws.IsServerConn() {
+		// for inbound connection remote addr is the origin
+		// so take real address from the request
+		p.state.RemoteAddr = ws.Request().RemoteAddr
+	}
+
+	// handshake is always the first message
+	first := n.handler.First()
+	p.Send(&Message{Type: VERSION, Version: &Version{
+		Protocol:   ProtocolVersion,
+		Height:     n.handler.Height(),
+		Addr:       n.Addr(),
+		Pruned:     first > 0,
+		FirstBlock: first,
+	}})
+
+	go p.write()
+	go p.ping()
+	return p
+}
+
+// Id returns peer id
+func (p *Peer) Id() uint64 {
+	return p.state.Id
+}
+
+// Addr returns address advertised by the peer
+func (p *Peer) Addr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state.Addr
+}
+
+// Info returns current peer state
+func (p *Peer) Info() PeerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+// Send puts message to the peer outbound queue without blocking.
+// If queue is full message is dropped, and if too many messages
+// were dropped in a row peer is disconnected
+func (p *Peer) Send(m *Message) {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	select {
+	case p.send <- m:
+		p.mu.Lock()
+		p.dropped = 0
+		p.mu.Unlock()
+	default:
+		p.mu.Lock()
+		p.dropped++
+		dropped := p.dropped
+		p.mu.Unlock()
+
+		p.network.log.Println("Queue of", p.Addr(), "node is full, message dropped")
+		if dropped >= maxDropped {
+			// peer is too slow -> disconnect
+			p.Close()
+		}
+	}
+}
+
+// Receive reads next message from the peer
+func (p *Peer) Receive(m *Message) error {
+	var data []byte
+
+	// node has to send something (at least pong)
+	// until read deadline, otherwise it is dead
+	p.Conn.SetReadDeadline(time.Now().Add(readWait))
+	err := websocket.Message.Receive(p.Conn, &data)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.state.BytesRecv += uint64(len(data))
+	p.state.MsgsRecv++
+	p.mu.Unlock()
+
+	return json.Unmarshal(data, m)
+}
+
+// Close disconnects peer and removes it from the network
+func (p *Peer) Close() {
+	p.once.Do(func() {
+		close(p.done)
+		p.Conn.Close()
+		p.network.remove(p)
+	})
+}
+
+// Disconnect sends goodbye to the peer after all queued messages
+// and closes connection when it is sent or context is done
+func (p *Peer) Disconnect(ctx context.Context) {
+	select {
+	case p.send <- &Message{Type: GOODBYE}:
+	default:
+		// queue is full -> no time for goodbye
+		p.Close()
+	}
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		p.Close()
+	}
+}
+
+// SetHeight updates best known peer block index
+func (p *Peer) SetHeight(height int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if height > p.state.Height {
+		p.state.Height = height
+	}
+}
+
+// Misbehave increases peer ban score and disconnects it
+// if score reached the limit
+func (p *Peer) Misbehave(score int, reason string) {
+	p.mu.Lock()
+	p.state.BanScore += score
+	banScore := p.state.BanScore
+	p.mu.Unlock()
+
+	p.network.log.Println(p.Addr(), "node misbehaved:", reason, "ban score", banScore)
+	if banScore >= maxBanScore {
+		p.Close()
+	}
+}
+
+// write messages from queue to the peer
+func (p *Peer) write() {
+	for {
+		select {
+		case m := <-p.send:
+			data, err := json.Marshal(m)
+			if err != nil {
+				panic(err)
+			}
+
+			p.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = websocket.Message.Send(p.Conn, string(data))
+			if err == nil {
+				p.mu.Lock()
+				p.state.BytesSent += uint64(len(data))
+				p.state.MsgsSent++
+				p.mu.Unlock()
+				p.network.sent.Inc(TypeName(m.Type))
+			}
+			if err != nil || m.Type == GOODBYE {
+				// if err or goodbye is sent -> node disconnect
+				p.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// send pings to the peer and disconnect it
+// if it does not respond to them
+func (p *Peer) ping() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			p.missedPings++
+			missed := p.missedPings
+			p.mu.Unlock()
+
+			if missed > maxMissedPings {
+				p.network.log.Println(p.Addr(), "node missed", maxMissedPings, "pings")
+				p.Close()
+				return
+			}
+
+			p.Send(&Message{Type: PING, Ping: time.Now().UnixNano()})
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// handle pong from the peer and update its latency
+func (p *Peer) pong(sent int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.missedPings = 0
+	p.state.Latency = time.Since(time.Unix(0, sent))
+}
+
+// handle peer handshake
+func (p *Peer) version(v *Version) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.state.Version = v.Protocol
+	p.state.Height = v.Height
+	p.state.Pruned, p.state.FirstBlock = v.Pruned, v.FirstBlock
+	if v.Addr != "" {
+		p.state.Addr = v.Addr
+	}
+}
@@ -0,0 +1,109 @@
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// max count of nodes remembered in peers database
+const maxKnownPeers = 256
+
+// KnownPeer type for store node in peers database
+type KnownPeer struct {
+	Addr     string    `json:"addr"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// PeersDB type for remember nodes across restarts
+type PeersDB struct {
+	mu sync.Mutex
+	// database file path
+	path  string
+	Peers []*KnownPeer `json:"peers"`
+}
+
+// LoadPeersDB loads peers database from data dir,
+// missing file means empty database and empty
+// data dir means database that is not saved
+func LoadPeersDB(dataDir string) (*PeersDB, error) {
+	db := &PeersDB{}
+	if dataDir == "" {
+		return db, nil
+	}
+
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	db.path = filepath.Join(dataDir, "peers.json")
+
+	data, err := ioutil.ReadFile(db.path)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db, json.Unmarshal(data, db)
+}
+
+// Addrs returns known nodes addresses, recently seen first
+func (db *PeersDB) Addrs() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	addrs := make([]string, len(db.Peers))
+	for i, p := range db.Peers {
+		addrs[i] = p.Addr
+	}
+	return addrs
+}
+
+// Seen remembers node address and saves database
+func (db *PeersDB) Seen(addr string) error {
+	if db.path == "" || addr == "" {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// move node to the top
+	for i, p := range db.Peers {
+		if p.Addr == addr {
+			db.Peers = append(db.Peers[:i], db.Peers[i+1:]...)
+			break
+		}
+	}
+	db.Peers = append([]*KnownPeer{{Addr: addr, LastSeen: time.Now()}}, db.Peers...)
+	if len(db.Peers) > maxKnownPeers {
+		db.Peers = db.Peers[:maxKnownPeers]
+	}
+
+	return db.save()
+}
+
+// Save writes database to the file
+func (db *PeersDB) Save() error {
+	if db.path == "" {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.save()
+}
+
+// write database to the file, lock must be held
+func (db *PeersDB) save() error {
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(db.path, data, 0644)
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+var (
+	// ErrInvalidType means that fact type name is invalid
+	ErrInvalidType = errors.New("invalid fact type, want namespace/name")
+	// ErrUnknownType means that fact type is not registered
+	ErrUnknownType = errors.New("unknown fact type")
+	// ErrTypeExists means that fact type is registered with other schema
+	ErrTypeExists = errors.New("fact type is already registered with other schema")
+	// ErrTypeRequired means that fact without type is not accepted
+	ErrTypeRequired = errors.New("fact type is required")
+	// ErrNullFact means that fact is null
+	ErrNullFact = errors.New("fact must not be null")
+)
+
+// fact type is namespace and name separated by slash
+var typeName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*/[a-z0-9][a-z0-9_.-]*$`)
+
+// Entry type for store fact type with its schema
+type Entry struct {
+	Type   string          `json:"type"`
+	Schema json.RawMessage `json:"schema"`
+	schema *Schema
+}
+
+// Registry type for store registered fact types
+type Registry struct {
+	mu sync.RWMutex
+	// registry file path
+	path string
+	// facts without type are rejected
+	requireType bool
+	entries     map[string]*Entry
+}
+
+// LoadRegistry loads registry from data dir, missing file
+// means empty registry and empty data dir means registry
+// that is not saved
+func LoadRegistry(dataDir string, requireType bool) (*Registry, error) {
+	r := &Registry{requireType: requireType, entries: make(map[string]*Entry)}
+	if dataDir == "" {
+		return r, nil
+	}
+
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	r.path = filepath.Join(dataDir, "schemas.json")
+
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		e.schema, err = Compile(e.Schema)
+		if err != nil {
+			return nil, errors.New(r.path + ": " + e.Type + ": " + err.Error())
+		}
+		// file is indented, schemas are kept in compact form
+		buf := &bytes.Buffer{}
+		json.Compact(buf, e.Schema)
+		e.Schema = buf.Bytes()
+		r.entries[e.Type] = e
+	}
+	return r, nil
+}
+
+// Register registers fact type with schema and saves registry,
+// returns false if the same schema is already registered.
+// Registered schema can't be changed
+func (r *Registry) Register(typ string, data []byte) (*Entry, bool, error) {
+	if !typeName.MatchString(typ) {
+		return nil, false, ErrInvalidType
+	}
+	s, err := Compile(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// schemas are compared in compact form
+	buf := &bytes.Buffer{}
+	err = json.Compact(buf, data)
+	if err != nil {
+		return nil, false, err
+	}
+	e := &Entry{Type: typ, Schema: buf.Bytes(), schema: s}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.entries[typ]; ok {
+		if !bytes.Equal(old.Schema, e.Schema) {
+			return nil, false, ErrTypeExists
+		}
+		return old, false, nil
+	}
+
+	r.entries[typ] = e
+	return e, true, r.save()
+}
+
+// Get returns fact type with its schema
+func (r *Registry) Get(typ string) (*Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.entries[typ]
+	return e, ok
+}
+
+// List returns all fact types sorted by name
+func (r *Registry) List() []*Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sorted()
+}
+
+// returns entries sorted by type, lock must be held
+func (r *Registry) sorted() []*Entry {
+	entries := make([]*Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Type < entries[j].Type
+	})
+	return entries
+}
+
+// Validate checks that fact is not null and matches schema of its type,
+// only type of fact with data kept off-chain is checked
+func (r *Registry) Validate(fact *chain.Fact) error {
+	offChain := fact.Fact == nil && fact.Blob != ""
+	if !offChain && (fact.Fact == nil || *fact.Fact == nil) {
+		return ErrNullFact
+	}
+	if fact.Type == "" {
+		if r.requireType {
+			return ErrTypeRequired
+		}
+		return nil
+	}
+
+	e, ok := r.Get(fact.Type)
+	if !ok {
+		return ErrUnknownType
+	}
+	if offChain {
+		// data is validated by node it is submitted to
+		return nil
+	}
+	return e.schema.Validate(*fact.Fact)
+}
+
+// write registry to the file, lock must be held
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// returns fact with data decoded from json
+func newFact(typ, data string) *chain.Fact {
+	fact := &chain.Fact{Id: "1", Type: typ}
+	if data != "" {
+		var v interface{}
+		json.Unmarshal([]byte(data), &v)
+		fact.Fact = &v
+	}
+	return fact
+}
+
+func TestRegistryValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		requireType bool
+		fact        *chain.Fact
+		err         error
+		// fact doesn't match schema
+		invalid bool
+	}{
+		{name: "without type", fact: newFact("", `{"a":1}`)},
+		{name: "type required", requireType: true, fact: newFact("", `{"a":1}`), err: ErrTypeRequired},
+		{name: "unknown type", fact: newFact("test/other", `{"a":1}`), err: ErrUnknownType},
+		{name: "missing fact", fact: newFact("test/item", ""), err: ErrNullFact},
+		{name: "null fact", fact: newFact("test/item", "null"), err: ErrNullFact},
+		{name: "matches schema", fact: newFact("test/item", `{"a":1}`)},
+		{name: "doesn't match schema", fact: newFact("test/item", `{"a":"1"}`), invalid: true},
+		{name: "off-chain", fact: &chain.Fact{Id: "1", Type: "test/item", Blob: "ab"}},
+		{name: "off-chain of unknown type", fact: &chain.Fact{Id: "1", Type: "test/other", Blob: "ab"}, err: ErrUnknownType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := LoadRegistry("", tt.requireType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = r.Register("test/item", []byte(`{"type":"object","required":["a"],"properties":{"a":{"type":"integer"}}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = r.Validate(tt.fact)
+			if tt.invalid {
+				if _, ok := err.(*ValidationError); !ok {
+					t.Fatalf("want validation error, got %v", err)
+				}
+				return
+			}
+			if err != tt.err {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    string
+		schema string
+		added  bool
+		err    error
+	}{
+		{name: "new type", typ: "test/new", schema: `{"type":"string"}`, added: true},
+		{name: "same schema", typ: "test/item", schema: `{ "type": "object" }`},
+		{name: "other schema", typ: "test/item", schema: `{"type":"string"}`, err: ErrTypeExists},
+		{name: "invalid type name", typ: "item", schema: `{}`, err: ErrInvalidType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := LoadRegistry("", false)
+			r.Register("test/item", []byte(`{"type":"object"}`))
+
+			_, added, err := r.Register(tt.typ, []byte(tt.schema))
+			if err != tt.err {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+			if added != tt.added {
+				t.Fatalf("want added %v, got %v", tt.added, added)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Package schema implements registry of fact types
+// and validation of facts with JSON Schemas.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidationError type for describe why value doesn't match schema
+type ValidationError struct {
+	// path to the invalid value like $.items[1].name
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error returns error message with path
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// types type for store schema type keyword, it is
+// either a single type or a list of types
+type types []string
+
+// UnmarshalJSON parses type from string or list of strings
+func (t *types) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*t = types{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Schema type for store JSON Schema.
+// Only following keywords are supported
+type Schema struct {
+	// annotations, not used for validation
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type types         `json:"type,omitempty"`
+	Enum []interface{} `json:"enum,omitempty"`
+
+	// object keywords
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+	// array keywords
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	// string keywords
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	pattern   *regexp.Regexp
+
+	// number keywords
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+}
+
+// known json types
+var knownTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// Compile parses schema, unsupported keywords are errors
+// so they are not silently ignored
+func Compile(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	s := &Schema{}
+	err := dec.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.compile("$")
+}
+
+// check keywords and compile patterns
+func (s *Schema) compile(path string) error {
+	for _, t := range s.Type {
+		if !knownTypes[t] {
+			return fmt.Errorf("%s: unknown type %q", path, t)
+		}
+	}
+
+	if s.Pattern != "" {
+		var err error
+		s.pattern, err = regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("%s.%s: schema is null", path, name)
+		}
+		err := p.compile(path + "." + name)
+		if err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// Validate checks that value decoded from json matches schema
+func (s *Schema) Validate(v interface{}) error {
+	return s.validate("$", v)
+}
+
+// validate value at path
+func (s *Schema) validate(path string, v interface{}) error {
+	invalid := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.Type) != 0 && !s.matchType(v) {
+		return invalid("must be %s", strings.Join(s.Type, " or "))
+	}
+	if len(s.Enum) != 0 && !s.inEnum(v) {
+		return invalid("must be one of enum values")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return invalid("missing required property %q", name)
+			}
+		}
+
+		// check properties in fixed order to get the same error
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return invalid("property %q is not allowed", name)
+				}
+				continue
+			}
+			err := p.validate(path+"."+name, v[name])
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return invalid("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return invalid("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		// length is counted in characters
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			return invalid("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return invalid("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return invalid("must match pattern %q", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return invalid("must be greater than or equal to %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return invalid("must be less than or equal to %v", *s.Maximum)
+		}
+	}
+	return nil
+}
+
+// check that value has one of schema types
+func (s *Schema) matchType(v interface{}) bool {
+	for _, t := range s.Type {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check that value is equal to one of enum values
+func (s *Schema) inEnum(v interface{}) bool {
+	for _, e := range s.Enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
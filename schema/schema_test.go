@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const person = `{
+		"type": "object",
+		"required": ["name"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "maxLength": 5, "pattern": "^[a-z]+$"},
+			"age": {"type": "integer", "minimum": 0, "maximum": 150},
+			"role": {"enum": ["admin", "user"]},
+			"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"type": "string"}},
+			"note": {"type": ["string", "null"]}
+		}
+	}`
+	tests := []struct {
+		name  string
+		value string
+		// path of invalid value, empty if value is valid
+		path string
+	}{
+		{name: "valid", value: `{"name":"bob","age":30,"role":"user","tags":["a"],"note":null}`},
+		{name: "not object", value: `"bob"`, path: "$"},
+		{name: "missing required", value: `{"age":30}`, path: "$"},
+		{name: "additional property", value: `{"name":"bob","x":1}`, path: "$"},
+		{name: "wrong type", value: `{"name":1}`, path: "$.name"},
+		{name: "too short", value: `{"name":""}`, path: "$.name"},
+		{name: "too long", value: `{"name":"robert"}`, path: "$.name"},
+		{name: "pattern", value: `{"name":"Bob"}`, path: "$.name"},
+		{name: "not integer", value: `{"name":"bob","age":30.5}`, path: "$.age"},
+		{name: "below minimum", value: `{"name":"bob","age":-1}`, path: "$.age"},
+		{name: "above maximum", value: `{"name":"bob","age":151}`, path: "$.age"},
+		{name: "not in enum", value: `{"name":"bob","role":"root"}`, path: "$.role"},
+		{name: "too few items", value: `{"name":"bob","tags":[]}`, path: "$.tags"},
+		{name: "too many items", value: `{"name":"bob","tags":["a","b","c"]}`, path: "$.tags"},
+		{name: "invalid item", value: `{"name":"bob","tags":["a",1]}`, path: "$.tags[1]"},
+		{name: "one of types", value: `{"name":"bob","note":1}`, path: "$.note"},
+	}
+
+	s, err := Compile([]byte(person))
+	if err != nil {
+		t.Fatalf("want schema compiled, got %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			err := json.Unmarshal([]byte(tt.value), &v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Validate(v)
+			if tt.path == "" {
+				if err != nil {
+					t.Fatalf("want valid, got %v", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("want validation error at %s, got %v", tt.path, err)
+			}
+			if verr.Path != tt.path {
+				t.Fatalf("want error at %s, got %v", tt.path, verr)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		ok     bool
+	}{
+		{name: "empty", schema: `{}`, ok: true},
+		{name: "annotations", schema: `{"$schema":"x","title":"t","description":"d"}`, ok: true},
+		{name: "unsupported keyword", schema: `{"oneOf":[]}`},
+		{name: "unknown type", schema: `{"type":"date"}`},
+		{name: "nested unknown type", schema: `{"properties":{"a":{"items":{"type":"date"}}}}`},
+		{name: "invalid pattern", schema: `{"pattern":"("}`},
+		{name: "null property", schema: `{"properties":{"a":null}}`},
+		{name: "not json", schema: `{`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if tt.ok && err != nil {
+				t.Fatalf("want compiled, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("want error, got nil")
+			}
+		})
+	}
+}
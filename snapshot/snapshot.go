@@ -0,0 +1,187 @@
+// Package snapshot implements snapshots of node state, joining
+// nodes start from snapshot instead of receiving all blocks.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// Version is version of snapshot format
+const Version = 1
+
+// name of the latest snapshot file in data dir
+const fileName = "snapshot.json"
+
+// ErrHash means that snapshot doesn't match its hash
+var ErrHash = errors.New("snapshot hash mismatch")
+
+// Snapshot type for store node state at block
+type Snapshot struct {
+	Version int `json:"version"`
+	// latest block header at the time of snapshot
+	Tip *chain.Header `json:"tip"`
+	// block indexes of confirmed facts by id
+	Facts map[string]int `json:"facts"`
+	// unconfirmed facts
+	Mempool   []*chain.Fact `json:"mempool"`
+	CreatedAt time.Time     `json:"created_at"`
+	// sha256 of snapshot without hash, commits to its content
+	Hash string `json:"hash"`
+}
+
+// New returns snapshot of blockchain tip, fact index and mempool
+func New(tip *chain.Header, facts map[string]int, mempool []*chain.Fact) *Snapshot {
+	s := &Snapshot{
+		Version:   Version,
+		Tip:       tip,
+		Facts:     facts,
+		Mempool:   mempool,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.Hash = s.CalcHash()
+	return s
+}
+
+// CalcHash returns hash of snapshot content,
+// maps are encoded with sorted keys, so it is stable
+func (s *Snapshot) CalcHash() string {
+	t := *s
+	t.Hash = ""
+	data, err := json.Marshal(&t)
+	if err != nil {
+		panic(err)
+	}
+	return chain.CalcHash(string(data))
+}
+
+// Check checks snapshot version and hash
+func (s *Snapshot) Check() error {
+	if s.Version != Version {
+		return fmt.Errorf("unsupported snapshot version %d, want %d", s.Version, Version)
+	}
+	if s.Tip == nil {
+		return errors.New("snapshot has no tip")
+	}
+	if s.CalcHash() != s.Hash {
+		return ErrHash
+	}
+	return nil
+}
+
+// Verify checks snapshot against headers of blocks from genesis
+// to snapshot tip and the latest blocks ending with tip block
+// received from other nodes, returns blockchain base the tip
+// block follows. Headers are checked with their hashes and proof
+// of work, but facts of blocks before the latest ones are not
+// received, so fact index of these blocks is trusted. Snapshot
+// must be confirmed by another node or by checkpoint for it
+func (s *Snapshot) Verify(headers []*chain.Header, blocks []*chain.Block) (*chain.Base, error) {
+	err := s.Check()
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) != s.Tip.Index+1 {
+		return nil, fmt.Errorf("want %d headers, got %d", s.Tip.Index+1, len(headers))
+	}
+
+	for i, h := range headers {
+		if h.Index != i {
+			return nil, fmt.Errorf("header %d has index %d", i, h.Index)
+		}
+		if i == 0 && h.PrevHash != "" || i > 0 && h.PrevHash != headers[i-1].Hash {
+			return nil, fmt.Errorf("header %d doesn't follow previous header", i)
+		}
+		// header has merkle root of block facts,
+		// so block hash is calculated by it
+		if h.CalcHash() != h.Hash {
+			return nil, fmt.Errorf("header %d doesn't match its hash", i)
+		}
+		// genesis block is not mined
+		if i > 0 && !h.Solved() {
+			return nil, fmt.Errorf("nonce of block %d doesn't solve it", i)
+		}
+	}
+	if !sameHeader(headers[len(headers)-1], s.Tip) {
+		return nil, errors.New("snapshot tip doesn't match headers")
+	}
+
+	if len(blocks) == 0 || blocks[len(blocks)-1].Index != s.Tip.Index {
+		return nil, errors.New("no tip block")
+	}
+	// blocks commit to their facts by hash, proof
+	// of work is checked by their headers
+	for i, blk := range blocks {
+		if blk.Index < 0 || blk.Index > s.Tip.Index ||
+			i > 0 && (blk.Index != blocks[i-1].Index+1 || blk.PrevHash != blocks[i-1].Hash) {
+			return nil, fmt.Errorf("block %d doesn't follow previous block", blk.Index)
+		}
+		if blk.CalcHash() != blk.Hash || !sameHeader(blk.Header(), headers[blk.Index]) {
+			return nil, fmt.Errorf("block %d doesn't match its header", blk.Index)
+		}
+		for _, fact := range blk.Facts {
+			j, ok := s.Facts[fact.Id]
+			if !ok || j != blk.Index {
+				return nil, fmt.Errorf("fact %s of block %d is not in snapshot", fact.Id, blk.Index)
+			}
+		}
+	}
+	for id, i := range s.Facts {
+		if i < 0 || i > s.Tip.Index {
+			return nil, fmt.Errorf("fact %s is in unknown block %d", id, i)
+		}
+	}
+
+	return &chain.Base{Headers: headers[:len(headers)-1], Facts: s.Facts}, nil
+}
+
+// compare headers, timestamps may be in different locations
+func sameHeader(a, b *chain.Header) bool {
+	t := *a
+	t.Timestamp = b.Timestamp
+	return t == *b && a.Timestamp.Equal(b.Timestamp)
+}
+
+// Load returns the latest snapshot saved in data dir, nil if there is no one
+func Load(dataDir string) (*Snapshot, error) {
+	path := filepath.Join(dataDir, fileName)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{}
+	err = json.Unmarshal(data, s)
+	if err == nil {
+		err = s.Check()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Save saves snapshot in data dir replacing the previous one
+func (s *Snapshot) Save(dataDir string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dataDir, fileName)
+	err = ioutil.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
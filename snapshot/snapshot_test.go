@@ -0,0 +1,238 @@
+package snapshot
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+// returns valid blockchain of count blocks, their
+// headers and snapshot of the latest block
+func newChain(count int) (*Snapshot, []*chain.Header, []*chain.Block) {
+	blocks := chaintest.NewChain(count)
+	headers := make([]*chain.Header, len(blocks))
+	facts := make(map[string]int)
+	for i, blk := range blocks {
+		headers[i] = blk.Header()
+		for _, fact := range blk.Facts {
+			facts[fact.Id] = blk.Index
+		}
+	}
+	return New(headers[len(headers)-1], facts, nil), headers, blocks
+}
+
+// replaces the latest block with block changed by change,
+// so headers and snapshot tip match it
+func changeTip(s *Snapshot, headers []*chain.Header, blocks []*chain.Block, change func(blk *chain.Block)) {
+	tip := blocks[len(blocks)-1]
+	change(tip)
+	tip.Hash = tip.CalcHash()
+	headers[len(headers)-1] = tip.Header()
+	s.Tip = tip.Header()
+	s.Hash = s.CalcHash()
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Snapshot, headers []*chain.Header, blocks []*chain.Block) ([]*chain.Header, []*chain.Block)
+		// part of error message, empty if snapshot is valid
+		err string
+	}{
+		{
+			name:   "all blocks",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) { return h, b },
+		},
+		{
+			name: "latest blocks",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				return h, b[3:]
+			},
+		},
+		{
+			name: "unsupported version",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Version = Version + 1
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "unsupported snapshot version",
+		},
+		{
+			name: "hash mismatch",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Facts["x"] = 1
+				return h, b
+			},
+			err: "snapshot hash mismatch",
+		},
+		{
+			name: "no tip",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Tip = nil
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "snapshot has no tip",
+		},
+		{
+			name: "missing headers",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				return h[1:], b
+			},
+			err: "want 5 headers",
+		},
+		{
+			name: "header with wrong index",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				h[2].Index = 3
+				return h, b
+			},
+			err: "header 2 has index 3",
+		},
+		{
+			name: "header doesn't follow previous",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				h[2].PrevHash = h[0].Hash
+				return h, b
+			},
+			err: "header 2 doesn't follow",
+		},
+		{
+			name: "header with bad hash",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				h[2].FactsRoot = chain.CalcHash("other")
+				return h, b[3:]
+			},
+			err: "header 2 doesn't match its hash",
+		},
+		{
+			name: "unsolved header",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				for i := 0; h[2].Solved(); i++ {
+					h[2].Nonce = strconv.Itoa(i)
+				}
+				return h, b[3:]
+			},
+			err: "nonce of block 2",
+		},
+		{
+			name: "tip with other hash",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				tip := *s.Tip
+				tip.Hash = chain.CalcHash("other")
+				s.Tip = &tip
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "tip doesn't match headers",
+		},
+		{
+			name: "no blocks",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				return h, nil
+			},
+			err: "no tip block",
+		},
+		{
+			name: "no tip block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				return h, b[:4]
+			},
+			err: "no tip block",
+		},
+		{
+			name: "missing block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				return h, append(b[1:2], b[3:]...)
+			},
+			err: "block 3 doesn't follow",
+		},
+		{
+			name: "block doesn't match header",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				// nonce is not a part of block hash
+				b[4].Nonce += "0"
+				return h, b[4:]
+			},
+			err: "block 4 doesn't match its header",
+		},
+		{
+			name: "bad block hash",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				changeTip(s, h, b, func(blk *chain.Block) {})
+				b[4].Facts[0].Id = "other"
+				return h, b
+			},
+			err: "block 4 doesn't match its header",
+		},
+		{
+			name: "unsolved block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				changeTip(s, h, b, func(blk *chain.Block) { blk.Complexity = 64 })
+				return h, b
+			},
+			err: "nonce of block 4",
+		},
+		{
+			name: "fact is not in snapshot",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				delete(s.Facts, "4")
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "fact 4 of block 4 is not in snapshot",
+		},
+		{
+			name: "fact is in other block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Facts["4"] = 3
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "fact 4 of block 4 is not in snapshot",
+		},
+		{
+			name: "fact in unknown block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Facts["x"] = 5
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "unknown block 5",
+		},
+		{
+			name: "fact in negative block",
+			change: func(s *Snapshot, h []*chain.Header, b []*chain.Block) ([]*chain.Header, []*chain.Block) {
+				s.Facts["x"] = -1
+				s.Hash = s.CalcHash()
+				return h, b
+			},
+			err: "unknown block -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, headers, blocks := newChain(5)
+			headers, blocks = tt.change(s, headers, blocks)
+
+			base, err := s.Verify(headers, blocks)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want verified, got %v", err)
+			}
+			if len(base.Headers) != s.Tip.Index || base.Headers[len(base.Headers)-1].Hash != s.Tip.PrevHash {
+				t.Fatalf("want base of %d headers before tip, got %d", s.Tip.Index, len(base.Headers))
+			}
+		})
+	}
+}
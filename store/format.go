@@ -0,0 +1,168 @@
+// Package store implements block file format used for
+// chain export and import and block storage in data dir.
+//
+// File starts with magic "blkchn" and uint16 format version
+// followed by records, each record is uint32 length of block,
+// uint32 CRC-32C checksum of block and block in json. Integers
+// are big endian. Blocks are written in order starting from
+// genesis block, so file can be written and read as stream.
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+const (
+	// magic of block file
+	magic = "blkchn"
+	// Version is version of block file format
+	Version = 1
+	// max size of block record
+	maxRecordSize = 64 << 20
+)
+
+var (
+	// ErrFormat means that data is not block file
+	ErrFormat = errors.New("not a block file")
+	// ErrChecksum means that block record is damaged
+	ErrChecksum = errors.New("block checksum mismatch")
+)
+
+// crc32c table
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Writer type for write blocks in block file format
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter writes file header and returns writer of blocks
+func NewWriter(w io.Writer) (*Writer, error) {
+	t := &Writer{w: bufio.NewWriter(w)}
+
+	header := make([]byte, len(magic)+2)
+	copy(header, magic)
+	binary.BigEndian.PutUint16(header[len(magic):], Version)
+	_, err := t.w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write writes block record, records are buffered until Flush
+func (t *Writer) Write(blk *chain.Block) error {
+	data, err := json.Marshal(blk)
+	if err != nil {
+		return err
+	}
+
+	var prefix [8]byte
+	binary.BigEndian.PutUint32(prefix[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(prefix[4:], crc32.Checksum(data, crcTable))
+	_, err = t.w.Write(prefix[:])
+	if err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
+}
+
+// Flush writes buffered records
+func (t *Writer) Flush() error {
+	return t.w.Flush()
+}
+
+// Reader type for read blocks of block file
+type Reader struct {
+	r *bufio.Reader
+	// size of file header and records read completely
+	offset int64
+}
+
+// NewReader reads file header and returns reader of blocks
+func NewReader(r io.Reader) (*Reader, error) {
+	t := &Reader{r: bufio.NewReader(r)}
+
+	header := make([]byte, len(magic)+2)
+	_, err := io.ReadFull(t.r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || err == nil && string(header[:len(magic)]) != magic {
+		return nil, ErrFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v := binary.BigEndian.Uint16(header[len(magic):]); v != Version {
+		return nil, fmt.Errorf("unsupported block file version %d, want %d", v, Version)
+	}
+
+	t.offset = int64(len(header))
+	return t, nil
+}
+
+// Read returns the next block, io.EOF at the end of file and
+// io.ErrUnexpectedEOF if the last record is not complete
+func (t *Reader) Read() (*chain.Block, error) {
+	var prefix [8]byte
+	_, err := io.ReadFull(t.r, prefix[:])
+	if err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[:4])
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("block record of %d bytes is too large", size)
+	}
+
+	data := make([]byte, size)
+	_, err = io.ReadFull(t.r, data)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(prefix[4:]) {
+		return nil, ErrChecksum
+	}
+
+	blk := &chain.Block{}
+	err = json.Unmarshal(data, blk)
+	if err != nil {
+		return nil, err
+	}
+	t.offset += int64(len(prefix)) + int64(size)
+	return blk, nil
+}
+
+// Offset returns size of file header and blocks read
+func (t *Reader) Offset() int64 {
+	return t.offset
+}
+
+// Check checks that block can follow previous one and
+// its nonce solves it, previous block is nil for genesis block
+func Check(prev, blk *chain.Block) error {
+	if prev == nil {
+		if blk.Index != 0 || blk.CalcHash() != blk.Hash {
+			return fmt.Errorf("block %d: invalid genesis block", blk.Index)
+		}
+		return nil
+	}
+	if !blk.Solved() {
+		return fmt.Errorf("block %d: nonce %q doesn't solve block of complexity %d", blk.Index, blk.Nonce, blk.Complexity)
+	}
+	// complexity depends on difficulty of the node, it is
+	// checked by blockchain on receive and by import
+	if blk.Index != prev.Index+1 || blk.PrevHash != prev.Hash || blk.CalcHash() != blk.Hash {
+		return fmt.Errorf("block %d: %v", blk.Index, chain.ErrInvalidBlock)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+// size of file header and of record prefix
+const (
+	headerSize = len(magic) + 2
+	prefixSize = 8
+)
+
+// returns blocks in block file format
+func encode(t *testing.T, blocks []*chain.Block) []byte {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blk := range blocks {
+		err = w.Write(blk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// returns record of data with valid checksum
+func record(data []byte) []byte {
+	rec := make([]byte, prefixSize, prefixSize+len(data))
+	binary.BigEndian.PutUint32(rec[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(rec[4:], crc32.Checksum(data, crcTable))
+	return append(rec, data...)
+}
+
+func TestReader(t *testing.T) {
+	blocks := chaintest.NewChain(3)
+	file := encode(t, blocks)
+	// offset of the second record
+	second := headerSize + prefixSize + int(binary.BigEndian.Uint32(file[headerSize:]))
+
+	tests := []struct {
+		name   string
+		change func(data []byte) []byte
+		// error of reading file header
+		headerErr error
+		// count of blocks read before error
+		read int
+		err  error
+		// any error other than listed ones is expected
+		otherErr bool
+	}{
+		{name: "valid", change: func(d []byte) []byte { return d }, read: 3, err: io.EOF},
+		{name: "header only", change: func(d []byte) []byte { return d[:headerSize] }, err: io.EOF},
+		{name: "empty", change: func(d []byte) []byte { return nil }, headerErr: ErrFormat},
+		{name: "short header", change: func(d []byte) []byte { return d[:3] }, headerErr: ErrFormat},
+		{
+			name: "bad magic",
+			change: func(d []byte) []byte {
+				d[0] = 'x'
+				return d
+			},
+			headerErr: ErrFormat,
+		},
+		{
+			name: "unsupported version",
+			change: func(d []byte) []byte {
+				binary.BigEndian.PutUint16(d[len(magic):], Version+1)
+				return d
+			},
+			otherErr: true,
+		},
+		{
+			name: "corrupted block",
+			change: func(d []byte) []byte {
+				d[second+prefixSize+1] ^= 0xff
+				return d
+			},
+			read: 1, err: ErrChecksum,
+		},
+		{
+			name: "corrupted checksum",
+			change: func(d []byte) []byte {
+				d[second+4] ^= 0xff
+				return d
+			},
+			read: 1, err: ErrChecksum,
+		},
+		{name: "truncated prefix", change: func(d []byte) []byte { return d[:second+3] }, read: 1, err: io.ErrUnexpectedEOF},
+		{name: "prefix without block", change: func(d []byte) []byte { return d[:second+prefixSize] }, read: 1, err: io.ErrUnexpectedEOF},
+		{name: "truncated block", change: func(d []byte) []byte { return d[:len(d)-3] }, read: 2, err: io.ErrUnexpectedEOF},
+		{
+			name: "too large record",
+			change: func(d []byte) []byte {
+				binary.BigEndian.PutUint32(d[second:], maxRecordSize+1)
+				return d
+			},
+			read: 1, otherErr: true,
+		},
+		{
+			name:     "not json",
+			change:   func(d []byte) []byte { return append(d[:second], record([]byte("{"))...) },
+			read:     1,
+			otherErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.change(append([]byte(nil), file...))
+
+			r, err := NewReader(bytes.NewReader(data))
+			if tt.otherErr && tt.read == 0 {
+				if err == nil {
+					t.Fatalf("want header error, got nil")
+				}
+				return
+			}
+			if err != tt.headerErr {
+				t.Fatalf("want header error %v, got %v", tt.headerErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			read := 0
+			for {
+				blk, rerr := r.Read()
+				if rerr != nil {
+					err = rerr
+					break
+				}
+				if blk.Hash != blocks[read].Hash {
+					t.Fatalf("block %d: want hash %s, got %s", read, blocks[read].Hash, blk.Hash)
+				}
+				read++
+			}
+			if read != tt.read {
+				t.Fatalf("want %d blocks read, got %d", tt.read, read)
+			}
+			if tt.otherErr {
+				if err == io.EOF || err == io.ErrUnexpectedEOF || err == ErrChecksum {
+					t.Fatalf("want other error, got %v", err)
+				}
+			} else if err != tt.err {
+				t.Fatalf("want error %v, got %v", tt.err, err)
+			}
+
+			// offset ends at the last complete record
+			want := int64(headerSize)
+			for _, blk := range blocks[:read] {
+				want += int64(len(encode(t, []*chain.Block{blk})) - headerSize)
+			}
+			if r.Offset() != want {
+				t.Fatalf("want offset %d, got %d", want, r.Offset())
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(blocks []*chain.Block) (prev, blk *chain.Block)
+		ok     bool
+	}{
+		{name: "genesis", change: func(b []*chain.Block) (*chain.Block, *chain.Block) { return nil, b[0] }, ok: true},
+		{name: "next block", change: func(b []*chain.Block) (*chain.Block, *chain.Block) { return b[0], b[1] }, ok: true},
+		{name: "not genesis first", change: func(b []*chain.Block) (*chain.Block, *chain.Block) { return nil, b[1] }},
+		{
+			name: "bad genesis hash",
+			change: func(b []*chain.Block) (*chain.Block, *chain.Block) {
+				b[0].Hash = chain.CalcHash("changed")
+				return nil, b[0]
+			},
+		},
+		{
+			name: "unsolved block",
+			change: func(b []*chain.Block) (*chain.Block, *chain.Block) {
+				chaintest.Unsolve(b[1])
+				return b[0], b[1]
+			},
+		},
+		{name: "broken link", change: func(b []*chain.Block) (*chain.Block, *chain.Block) { return b[0], b[2] }},
+		{
+			name: "bad hash",
+			change: func(b []*chain.Block) (*chain.Block, *chain.Block) {
+				b[1].Hash = chain.CalcHash("changed")
+				return b[0], b[1]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.change(chaintest.NewChain(3)))
+			if tt.ok && err != nil {
+				t.Fatalf("want valid, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("want error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+const (
+	// name of block file in data dir
+	fileName = "blocks.dat"
+	// name of file of blockchain state before
+	// the first stored block, see chain.Base
+	baseName = "base.json"
+	// suffix of files written before they replace stored ones
+	tmpSuffix = ".tmp"
+)
+
+// ErrNotEmpty means that data dir already has blocks
+var ErrNotEmpty = errors.New("data dir already has blocks")
+
+// Store type for keep blocks of the blockchain in data dir
+type Store struct {
+	mu sync.Mutex
+	// block file path
+	path string
+	// base file path
+	basePath string
+	f        *os.File
+	w        *Writer
+}
+
+// Open opens block file of data dir and returns it with blockchain
+// of stored blocks, each block is checked while loading. Block file
+// is created if it is missing. Incomplete last block left by crash
+// is dropped, other damages are returned as errors
+func Open(dataDir string) (*Store, *chain.Chain, error) {
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &Store{path: Path(dataDir), basePath: filepath.Join(dataDir, baseName)}
+
+	base, pending, err := loadBase(dataDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", s.basePath, err)
+	}
+	if pending {
+		// block file is replaced, but base file is not yet
+		err = commitBase(s.basePath)
+	} else {
+		// replacement is not finished, its files are dropped
+		err = os.Remove(s.basePath + tmpSuffix)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	blocks, offset, err := load(s.path, base)
+	if os.IsNotExist(err) {
+		return s, chain.New(nil), s.Replace(nil, nil)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", s.path, err)
+	}
+
+	s.f, err = os.OpenFile(s.path, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	// drop incomplete block and append after the last one
+	err = s.f.Truncate(offset)
+	if err == nil {
+		_, err = s.f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		s.f.Close()
+		return nil, nil, err
+	}
+	s.w = &Writer{w: bufio.NewWriter(s.f)}
+
+	if base != nil {
+		return s, chain.NewWithBase(base, blocks), nil
+	}
+	return s, chain.New(blocks), nil
+}
+
+// Path returns path of block file in data dir
+func Path(dataDir string) string {
+	return filepath.Join(dataDir, fileName)
+}
+
+// Load returns blocks stored in data dir without opening
+// block file for writing, so it can be used by running node
+func Load(dataDir string) ([]*chain.Block, error) {
+	base, _, err := loadBase(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	blocks, _, err := load(Path(dataDir), base)
+	return blocks, err
+}
+
+// read base of stored blocks. New base is written to temporary file
+// before block file is replaced and renamed after it, so temporary
+// base is taken if there is no temporary block file anymore. Pending
+// reports that temporary base file has to be renamed
+func loadBase(dataDir string) (base *chain.Base, pending bool, err error) {
+	path := filepath.Join(dataDir, baseName)
+	_, err = os.Stat(path + tmpSuffix)
+	if err == nil {
+		_, err = os.Stat(Path(dataDir) + tmpSuffix)
+		if os.IsNotExist(err) {
+			base, err = readBase(path + tmpSuffix)
+			return base, true, err
+		}
+	}
+	base, err = readBase(path)
+	return base, false, err
+}
+
+// read base file, missing file or null means full blockchain
+func readBase(path string) (*chain.Base, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var base *chain.Base
+	err = json.Unmarshal(data, &base)
+	if err != nil {
+		return nil, err
+	}
+	return base, nil
+}
+
+// read and check blocks of block file following base, returns
+// them and size of the file without incomplete block
+func load(path string, base *chain.Base) ([]*chain.Block, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var blocks []*chain.Block
+	for {
+		blk, err := r.Read()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return blocks, r.Offset(), nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("block %d: %v", len(blocks), err)
+		}
+
+		var prev *chain.Block
+		switch {
+		case len(blocks) != 0:
+			prev = blocks[len(blocks)-1]
+		case base != nil && len(base.Headers) != 0:
+			h := base.Headers[len(base.Headers)-1]
+			prev = &chain.Block{Index: h.Index, Hash: h.Hash}
+		}
+		err = Check(prev, blk)
+		if err != nil {
+			return nil, 0, err
+		}
+		blocks = append(blocks, blk)
+	}
+}
+
+// Append writes block appended to the blockchain
+func (s *Store) Append(blk *chain.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.w.Write(blk)
+	if err == nil {
+		err = s.w.Flush()
+	}
+	if err == nil {
+		err = s.f.Sync()
+	}
+	return err
+}
+
+// Replace writes all blocks of replaced blockchain and its base,
+// blocks are written to new file replacing the old one. Base file
+// is replaced after block file, interrupted replacement is finished
+// or dropped by Open
+func (s *Store) Replace(base *chain.Base, blocks []*chain.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := writeBase(s.basePath, base)
+	if err != nil {
+		return err
+	}
+	f, err := writeFile(s.path, func(w *Writer) error {
+		for _, blk := range blocks {
+			err := w.Write(blk)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(s.basePath + tmpSuffix)
+		return err
+	}
+	err = commitBase(s.basePath)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, s.w = f, &Writer{w: bufio.NewWriter(f)}
+	return nil
+}
+
+// Close closes block file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+// Import reads blocks of block file into data dir, each block
+// is checked while loading, complexity of blocks is checked
+// by difficulty of the node. Data dir blocks are replaced only
+// if all blocks are valid and replace is true, it must not be
+// used by running node. Returns count of imported blocks
+func Import(dataDir string, r io.Reader, d chain.Difficulty, replace bool) (int, error) {
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		return 0, err
+	}
+	path := Path(dataDir)
+	basePath := filepath.Join(dataDir, baseName)
+	if !replace {
+		base, _, err := loadBase(dataDir)
+		if base != nil || err != nil {
+			return 0, ErrNotEmpty
+		}
+		blocks, _, err := load(path, nil)
+		if len(blocks) != 0 || err != nil && !os.IsNotExist(err) {
+			return 0, ErrNotEmpty
+		}
+	}
+
+	br, err := NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	// imported blocks start from genesis block
+	err = writeBase(basePath, nil)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	f, err := writeFile(path, func(w *Writer) error {
+		var (
+			prev   *chain.Block
+			blocks []*chain.Block
+		)
+		for ; ; n++ {
+			blk, err := br.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("block %d: %v", n, err)
+			}
+
+			err = Check(prev, blk)
+			if err != nil {
+				return err
+			}
+			err = w.Write(blk)
+			if err != nil {
+				return err
+			}
+			prev = blk
+			blocks = append(blocks, blk)
+		}
+		if n == 0 {
+			return errors.New("no blocks to import")
+		}
+
+		// timestamps, complexity and fact ids are checked by whole blockchain
+		problems := chain.Verify(blocks, chain.VerifyOptions{
+			MaxFutureDrift: chain.MaxFutureDrift,
+			Difficulty:     d,
+		})
+		if len(problems) != 0 {
+			return fmt.Errorf("block %d: %s", problems[0].Index, problems[0].Message)
+		}
+		return nil
+	})
+	if err != nil {
+		os.Remove(basePath + tmpSuffix)
+		return 0, err
+	}
+	f.Close()
+	return n, commitBase(basePath)
+}
+
+// write base to temporary file, nil base is written as null
+func writeBase(path string, base *chain.Base) error {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path+tmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// replace base file with temporary one, base file
+// is removed if temporary base is null
+func commitBase(path string) error {
+	base, err := readBase(path + tmpSuffix)
+	if err != nil {
+		return err
+	}
+	if base != nil {
+		return os.Rename(path+tmpSuffix, path)
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(path + tmpSuffix)
+}
+
+// write block file to temporary file by write and replace file at
+// path with it, returns file opened for append. File is not changed
+// if write fails
+func writeFile(path string, write func(*Writer) error) (*os.File, error) {
+	tmp := path + tmpSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(f)
+	if err == nil {
+		err = write(w)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	return f, nil
+}
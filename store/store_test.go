@@ -0,0 +1,249 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+// returns temporary data dir, it is removed by caller
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writes file of data dir
+func createFile(t *testing.T, dir, name string, data []byte) {
+	err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checks that file of data dir exists or not
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func TestOpen(t *testing.T) {
+	blocks := chaintest.NewChain(4)
+	file := encode(t, blocks)
+	// base of blocks before the third one
+	base := `{"headers":[` + header(blocks[0]) + `,` + header(blocks[1]) + `],"facts":{"1":1}}`
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		// index of the first stored and the latest block
+		first, height int
+		err           bool
+		// files left in data dir
+		left []string
+	}{
+		{name: "new", first: 0, height: -1, left: []string{fileName}},
+		{name: "blocks", files: map[string][]byte{fileName: file}, height: 3},
+		{name: "incomplete last block", files: map[string][]byte{fileName: file[:len(file)-3]}, height: 2},
+		{
+			name: "corrupted block",
+			files: map[string][]byte{fileName: func() []byte {
+				d := append([]byte(nil), file...)
+				d[headerSize+prefixSize+1] ^= 0xff
+				return d
+			}()},
+			err: true,
+		},
+		{name: "not block file", files: map[string][]byte{fileName: []byte("blocks")}, err: true},
+		{
+			name: "replaced block file",
+			files: map[string][]byte{
+				fileName:             encode(t, blocks[2:]),
+				baseName + tmpSuffix: []byte(base),
+			},
+			first: 2, height: 3,
+			left: []string{fileName, baseName},
+		},
+		{
+			name: "interrupted replacement",
+			files: map[string][]byte{
+				fileName:             file,
+				fileName + tmpSuffix: encode(t, blocks[2:]),
+				baseName + tmpSuffix: []byte(base),
+			},
+			height: 3,
+			left:   []string{fileName},
+		},
+		{
+			name: "replaced by full blockchain",
+			files: map[string][]byte{
+				fileName:             file,
+				baseName:             []byte(base),
+				baseName + tmpSuffix: []byte("null"),
+			},
+			height: 3,
+			left:   []string{fileName},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			for name, data := range tt.files {
+				createFile(t, dir, name, data)
+			}
+
+			s, c, err := Open(dir)
+			if tt.err {
+				if err == nil {
+					s.Close()
+					t.Fatalf("want error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("want opened, got %v", err)
+			}
+			defer s.Close()
+
+			first := 0
+			if base := c.Base(); base != nil {
+				first = len(base.Headers)
+			}
+			if first != tt.first || c.Height() != tt.height {
+				t.Fatalf("want blocks from %d to %d, got from %d to %d", tt.first, tt.height, first, c.Height())
+			}
+			if tt.left != nil {
+				for _, name := range []string{fileName, baseName, baseName + tmpSuffix} {
+					want := false
+					for _, l := range tt.left {
+						want = want || l == name
+					}
+					if exists(dir, name) != want {
+						t.Fatalf("want %s exists %v, got %v", name, want, !want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// returns block header in json
+func header(blk *chain.Block) string {
+	data, _ := json.Marshal(blk.Header())
+	return string(data)
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(blocks []*chain.Block) []*chain.Block
+		replace bool
+		// data dir has blocks
+		stored bool
+		err    bool
+	}{
+		{name: "valid", change: func(b []*chain.Block) []*chain.Block { return b }},
+		{name: "replace", change: func(b []*chain.Block) []*chain.Block { return b }, stored: true, replace: true},
+		{name: "not empty", change: func(b []*chain.Block) []*chain.Block { return b }, stored: true, err: true},
+		{name: "no blocks", change: func(b []*chain.Block) []*chain.Block { return nil }, err: true},
+		{name: "without genesis", change: func(b []*chain.Block) []*chain.Block { return b[1:] }, err: true},
+		{name: "broken link", change: func(b []*chain.Block) []*chain.Block { return append(b[:1], b[2:]...) }, err: true},
+		{
+			name: "unsolved block",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Complexity = 64
+				b[2].Hash = b[2].CalcHash()
+				return b[:3]
+			},
+			err: true,
+		},
+		{
+			name: "wrong complexity",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Complexity = chaintest.Complexity + 1
+				b[2].Hash = b[2].CalcHash()
+				chaintest.Solve(b[2])
+				return b[:3]
+			},
+			err: true,
+		},
+		{
+			name: "duplicate fact",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Facts[0].Id = b[1].Facts[0].Id
+				b[2].Hash = b[2].CalcHash()
+				chaintest.Solve(b[2])
+				return b[:3]
+			},
+			err: true,
+		},
+		{
+			name: "block in the future",
+			change: func(b []*chain.Block) []*chain.Block {
+				b[2].Timestamp = time.Now().UTC().Add(chain.MaxFutureDrift + time.Hour)
+				b[2].Hash = b[2].CalcHash()
+				chaintest.Solve(b[2])
+				return b[:3]
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+			stored := chaintest.NewChain(2)
+			if tt.stored {
+				createFile(t, dir, fileName, encode(t, stored))
+			}
+
+			blocks := tt.change(chaintest.NewChain(4))
+			n, err := Import(dir, bytes.NewReader(encode(t, blocks)), chaintest.Difficulty, tt.replace)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("want error, got nil")
+				}
+			} else if err != nil {
+				t.Fatalf("want imported, got %v", err)
+			} else if n != len(blocks) {
+				t.Fatalf("want %d blocks imported, got %d", len(blocks), n)
+			}
+
+			// failed import doesn't change data dir
+			want := blocks
+			if tt.err {
+				want = nil
+				if tt.stored {
+					want = stored
+				}
+			}
+			got, err := Load(dir)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("want %d blocks stored, got %d", len(want), len(got))
+			}
+			for i := range got {
+				if got[i].Hash != want[i].Hash {
+					t.Fatalf("block %d: want hash %s, got %s", i, want[i].Hash, got[i].Hash)
+				}
+			}
+			if exists(dir, baseName+tmpSuffix) || exists(dir, fileName+tmpSuffix) {
+				t.Fatalf("want temporary files removed")
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+const (
+	// max count of attempts to deliver payload
+	maxAttempts = 6
+	// delay before the first retry, doubled after each retry
+	retryDelay = time.Second
+	// time allowed to deliver payload in one attempt
+	deliveryTimeout = 10 * time.Second
+)
+
+const (
+	// PENDING means that payload is not delivered yet
+	PENDING = "pending"
+	// DELIVERED means that hook accepted payload
+	DELIVERED = "delivered"
+	// FAILED means that all attempts failed
+	FAILED = "failed"
+	// SKIPPED means that blocks are pruned
+	// before their facts are sent
+	SKIPPED = "skipped"
+)
+
+// Delivery type for store state of payload delivery
+type Delivery struct {
+	Id         string `json:"id"`
+	HookId     string `json:"hook_id"`
+	BlockIndex int    `json:"block_index"`
+	BlockHash  string `json:"block_hash"`
+	FactCount  int    `json:"fact_count"`
+	// count of pruned blocks starting from block index
+	// which facts are not sent
+	Skipped int `json:"skipped,omitempty"`
+	// pending, delivered, failed or skipped
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// http status code of the latest attempt
+	StatusCode int `json:"status_code,omitempty"`
+	// error of the latest attempt
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Payload type for send confirmed facts to hook
+type Payload struct {
+	// delivery id, the same for all attempts
+	Id     string `json:"id"`
+	HookId string `json:"hook_id"`
+	// count of blocks starting from facts block
+	Confirmations int           `json:"confirmations"`
+	Block         *chain.Header `json:"block"`
+	Facts         []*chain.Fact `json:"facts"`
+}
+
+// client for deliveries
+var client = &http.Client{Timeout: deliveryTimeout}
+
+// wait for appended blocks and send facts
+// of blocks reaching hooks confirmation depth
+func (d *Dispatcher) loop() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.notify:
+			d.dispatch()
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// start deliveries of blocks reaching hooks confirmation depth
+func (d *Dispatcher) dispatch() {
+	height := d.chain.Height()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	changed := false
+	for _, h := range d.hooks {
+		if d.rewind(h) {
+			changed = true
+		}
+
+		// first of pruned blocks in a row
+		gap := -1
+		for ; h.Next <= height-h.Confirmations+1; h.Next++ {
+			changed = true
+			blk, ok := d.chain.Block(h.Next)
+			if !ok {
+				// block before snapshot is not kept
+				if gap < 0 {
+					gap = h.Next
+				}
+				h.Tip = ""
+				continue
+			}
+			if gap >= 0 {
+				d.skip(h, gap, h.Next-gap)
+				gap = -1
+			}
+			h.Tip = blk.Hash
+
+			p := newPayload(randomId(16), h, blk)
+			if len(p.Facts) == 0 {
+				continue
+			}
+			t := &Delivery{
+				Id:         p.Id,
+				HookId:     h.Id,
+				BlockIndex: blk.Index,
+				BlockHash:  blk.Hash,
+				FactCount:  len(p.Facts),
+				Status:     PENDING,
+				CreatedAt:  time.Now().UTC(),
+			}
+			t.UpdatedAt = t.CreatedAt
+			d.deliveries = append(d.deliveries, t)
+			d.trim()
+
+			d.wg.Add(1)
+			go d.deliver(t, h.URL, h.Secret, p)
+		}
+		if gap >= 0 {
+			d.skip(h, gap, h.Next-gap)
+		}
+	}
+
+	if changed {
+		// deliveries are saved before hooks cursors, so after
+		// crash block is delivered again rather than lost
+		err := d.saveDeliveries()
+		if err == nil {
+			err = d.save()
+		}
+		if err != nil {
+			d.log.Println("Save webhooks:", err)
+		}
+	}
+}
+
+// moves hook back when the block before its next one is replaced,
+// facts of blocks after the latest delivered block that is still
+// in blockchain are sent again, lock must be held
+func (d *Dispatcher) rewind(h *Hook) bool {
+	if h.Tip == "" || !d.replaced(h.Next-1, h.Tip) {
+		return false
+	}
+
+	next := h.From
+	for _, t := range d.deliveries {
+		if t.HookId != h.Id || t.Skipped != 0 || t.BlockIndex >= h.Next {
+			continue
+		}
+		if !d.replaced(t.BlockIndex, t.BlockHash) {
+			if t.BlockIndex >= next {
+				next = t.BlockIndex + 1
+			}
+		} else if t.Status == PENDING {
+			// facts of replaced block are not confirmed anymore
+			t.Status = FAILED
+			t.Error = "block is replaced"
+			t.UpdatedAt = time.Now().UTC()
+		}
+	}
+
+	d.log.Println("Webhook", h.Id, "block", h.Next-1, "is replaced, send facts again from block", next)
+	h.Next, h.Tip = next, ""
+	return true
+}
+
+// checks that block with hash is not in blockchain anymore,
+// pruned block is not replaced
+func (d *Dispatcher) replaced(index int, hash string) bool {
+	blk, ok := d.chain.Block(index)
+	if !ok {
+		return index > d.chain.Height()
+	}
+	return blk.Hash != hash
+}
+
+// report blocks pruned before their facts are sent, lock must be held
+func (d *Dispatcher) skip(h *Hook, from, count int) {
+	d.log.Println("Webhook", h.Id, "skipped", count, "pruned blocks from block", from)
+	t := &Delivery{
+		Id:         randomId(16),
+		HookId:     h.Id,
+		BlockIndex: from,
+		Skipped:    count,
+		Status:     SKIPPED,
+		Error:      "blocks are pruned, their facts can't be sent",
+		CreatedAt:  time.Now().UTC(),
+	}
+	t.UpdatedAt = t.CreatedAt
+	d.deliveries = append(d.deliveries, t)
+	d.trim()
+}
+
+// drop the oldest finished deliveries from delivery log,
+// pending ones are kept to be resumed, lock must be held
+func (d *Dispatcher) trim() {
+	drop := len(d.deliveries) - maxDeliveries
+	if drop <= 0 {
+		return
+	}
+	kept := make([]*Delivery, 0, maxDeliveries)
+	for _, t := range d.deliveries {
+		if drop > 0 && t.Status != PENDING {
+			drop--
+			continue
+		}
+		kept = append(kept, t)
+	}
+	d.deliveries = kept
+}
+
+// restart deliveries left pending by previous run
+func (d *Dispatcher) resume() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, t := range d.deliveries {
+		if t.Status != PENDING {
+			continue
+		}
+		h, ok := d.hooks[t.HookId]
+		blk, kept := d.chain.Block(t.BlockIndex)
+		if !ok || !kept || blk.Hash != t.BlockHash {
+			// facts can't be sent anymore
+			t.Status = FAILED
+			t.Error = "webhook or block is gone"
+			t.UpdatedAt = time.Now().UTC()
+			continue
+		}
+
+		d.wg.Add(1)
+		go d.deliver(t, h.URL, h.Secret, newPayload(t.Id, h, blk))
+	}
+}
+
+// returns payload with facts of block matching hook
+func newPayload(id string, h *Hook, blk *chain.Block) *Payload {
+	var facts []*chain.Fact
+	for _, fact := range blk.Facts {
+		if len(h.FactTypes) == 0 || contains(h.FactTypes, fact.Type) {
+			facts = append(facts, fact)
+		}
+	}
+	return &Payload{
+		Id:            id,
+		HookId:        h.Id,
+		Confirmations: h.Confirmations,
+		Block:         blk.Header(),
+		Facts:         facts,
+	}
+}
+
+// send payload to hook url retrying with backoff
+func (d *Dispatcher) deliver(t *Delivery, url, secret string, p *Payload) {
+	defer d.wg.Done()
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		panic(err)
+	}
+
+	// resumed delivery continues its attempts
+	d.mu.Lock()
+	first := t.Attempts + 1
+	d.mu.Unlock()
+
+	delay := retryDelay
+	for attempt := first; ; attempt++ {
+		code, retry, err := d.post(url, secret, t.Id, body)
+
+		d.mu.Lock()
+		if t.Status != PENDING {
+			// block is replaced meanwhile
+			d.mu.Unlock()
+			return
+		}
+		t.Attempts = attempt
+		t.StatusCode = code
+		t.UpdatedAt = time.Now().UTC()
+		t.Error = ""
+		switch {
+		case err == nil:
+			t.Status = DELIVERED
+		case !retry || attempt >= maxAttempts || !d.exists(t.HookId):
+			t.Status = FAILED
+			t.Error = err.Error()
+		default:
+			t.Error = err.Error()
+		}
+		status := t.Status
+		if status != PENDING {
+			err := d.saveDeliveries()
+			if err != nil {
+				d.log.Println("Save webhook deliveries:", err)
+			}
+		}
+		d.mu.Unlock()
+
+		if status != PENDING {
+			if status == FAILED {
+				d.log.Println("Webhook", t.HookId, "delivery", t.Id, "failed:", err)
+			}
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-d.ctx.Done():
+			// node stops, delivery stays pending
+			return
+		}
+	}
+}
+
+// check that hook is not removed, lock must be held
+func (d *Dispatcher) exists(id string) bool {
+	_, ok := d.hooks[id]
+	return ok
+}
+
+// Sign returns signature of payload body sent at timestamp
+// in unix seconds, hook can reject payloads signed long ago
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post payload signed with current time, returns status
+// code and whether failed attempt should be retried
+func (d *Dispatcher) post(url, secret, id string, body []byte) (int, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req = req.WithContext(d.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blkchn-webhook")
+	req.Header.Set("X-Blkchn-Delivery", id)
+	req.Header.Set("X-Blkchn-Timestamp", timestamp)
+	req.Header.Set("X-Blkchn-Signature", Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	// client errors are not retried except rate limit
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// check that list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
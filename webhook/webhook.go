@@ -0,0 +1,304 @@
+// Package webhook implements notifications of external
+// systems about confirmed facts over http.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+)
+
+// max count of deliveries kept in delivery log
+const maxDeliveries = 1000
+
+var (
+	// ErrInvalidURL means that webhook url is not http(s) url
+	ErrInvalidURL = errors.New("invalid webhook url, want http(s) url")
+	// ErrInvalidConfirmations means that confirmation depth is negative
+	ErrInvalidConfirmations = errors.New("confirmations must not be negative")
+)
+
+// Hook type for store webhook subscription
+type Hook struct {
+	Id  string `json:"id"`
+	URL string `json:"url"`
+	// fact types sent to the hook, all facts if empty
+	FactTypes []string `json:"fact_types,omitempty"`
+	// count of blocks starting from fact block
+	// needed to send fact, 1 means fact is in the latest block
+	Confirmations int `json:"confirmations"`
+	// key of payload signature,
+	// sent to client only when hook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// index of the next block to send
+	Next int `json:"next"`
+	// hash of the block before the next one, if it is
+	// replaced facts of the new blocks are sent again
+	Tip string `json:"tip,omitempty"`
+	// index of the first block sent to the hook
+	From int `json:"from"`
+}
+
+// Chain type for access blockchain from dispatcher
+type Chain interface {
+	// Height returns latest block index
+	Height() int
+	// Block returns block by index
+	Block(index int) (*chain.Block, bool)
+}
+
+// Dispatcher type for store webhooks and send confirmed facts to them
+type Dispatcher struct {
+	chain Chain
+	log   *log.Logger
+	// hooks file path
+	path string
+	// delivery log file path, pending deliveries
+	// are resumed after restart
+	deliveriesPath string
+
+	mu    sync.Mutex
+	hooks map[string]*Hook
+	// delivery log, the latest last
+	deliveries []*Delivery
+
+	// signals that blockchain has grown
+	notify chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	// running delivery loop and deliveries
+	wg sync.WaitGroup
+}
+
+// Load loads webhooks from data dir and starts dispatcher,
+// missing file means no webhooks and empty data dir means
+// webhooks that are not saved
+func Load(dataDir string, c Chain, logger *log.Logger) (*Dispatcher, error) {
+	d := &Dispatcher{
+		chain:  c,
+		log:    logger,
+		hooks:  make(map[string]*Hook),
+		notify: make(chan struct{}, 1),
+	}
+
+	if dataDir != "" {
+		err := os.MkdirAll(dataDir, 0755)
+		if err != nil {
+			return nil, err
+		}
+		d.path = filepath.Join(dataDir, "webhooks.json")
+
+		data, err := ioutil.ReadFile(d.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			var hooks []*Hook
+			err = json.Unmarshal(data, &hooks)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range hooks {
+				d.hooks[h.Id] = h
+			}
+		}
+
+		d.deliveriesPath = filepath.Join(dataDir, "webhook_deliveries.json")
+		data, err = ioutil.ReadFile(d.deliveriesPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &d.deliveries)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.resume()
+	d.wg.Add(1)
+	go d.loop()
+	return d, nil
+}
+
+// Add creates webhook and saves webhooks, hook receives facts
+// of blocks appended after it is created. Secret is generated if empty
+func (d *Dispatcher) Add(h Hook) (*Hook, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if h.Confirmations < 0 {
+		return nil, ErrInvalidConfirmations
+	}
+	if h.Confirmations == 0 {
+		h.Confirmations = 1
+	}
+	if h.Secret == "" {
+		h.Secret = randomId(32)
+	}
+	h.Id = randomId(8)
+	h.CreatedAt = time.Now().UTC()
+	h.Next = d.chain.Height() + 1
+	h.From, h.Tip = h.Next, ""
+	if blk, ok := d.chain.Block(h.Next - 1); ok {
+		h.Tip = blk.Hash
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.hooks[h.Id] = &h
+	t := h
+	return &t, d.save()
+}
+
+// Hooks returns webhooks without secrets sorted by creation time
+func (d *Dispatcher) Hooks() []*Hook {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hooks := d.sorted()
+	for i, h := range hooks {
+		t := *h
+		t.Secret = ""
+		hooks[i] = &t
+	}
+	return hooks
+}
+
+// returns hooks sorted by creation time, lock must be held
+func (d *Dispatcher) sorted() []*Hook {
+	hooks := make([]*Hook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks
+}
+
+// Hook returns webhook without secret by id
+func (d *Dispatcher) Hook(id string) (*Hook, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.hooks[id]
+	if !ok {
+		return nil, false
+	}
+	t := *h
+	t.Secret = ""
+	return &t, true
+}
+
+// Remove removes webhook and saves webhooks, pending
+// deliveries of the hook are not retried
+func (d *Dispatcher) Remove(id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.hooks[id]; !ok {
+		return false, nil
+	}
+	delete(d.hooks, id)
+	return true, d.save()
+}
+
+// Deliveries returns delivery log of webhook, the latest first
+func (d *Dispatcher) Deliveries(id string) []*Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deliveries := []*Delivery{}
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if d.deliveries[i].HookId == id {
+			t := *d.deliveries[i]
+			deliveries = append(deliveries, &t)
+		}
+	}
+	return deliveries
+}
+
+// Notify tells dispatcher that blocks are appended, it doesn't wait
+func (d *Dispatcher) Notify() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+		// dispatcher is already notified
+	}
+}
+
+// Close stops retries of pending deliveries, waits for running
+// ones and saves webhooks, pending deliveries are resumed by Load
+func (d *Dispatcher) Close() error {
+	d.cancel()
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.saveDeliveries()
+	if err != nil {
+		return err
+	}
+	return d.save()
+}
+
+// write webhooks to the file, lock must be held
+func (d *Dispatcher) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(d.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// file contains secrets
+	return ioutil.WriteFile(d.path, data, 0600)
+}
+
+// write delivery log to the file, lock must be held
+func (d *Dispatcher) saveDeliveries() error {
+	if d.deliveriesPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(d.deliveries)
+	if err != nil {
+		return err
+	}
+	tmp := d.deliveriesPath + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.deliveriesPath)
+}
+
+// returns random hex string of n bytes
+func randomId(n int) string {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lavrs/blkchn/chain"
+	"github.com/lavrs/blkchn/chain/chaintest"
+)
+
+// blockchain of test, blocks before first are pruned
+type testChain struct {
+	mu     sync.Mutex
+	blocks []*chain.Block
+	first  int
+}
+
+func (c *testChain) Height() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.blocks) - 1
+}
+
+func (c *testChain) Block(index int) (*chain.Block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if index < c.first || index >= len(c.blocks) {
+		return nil, false
+	}
+	return c.blocks[index], true
+}
+
+// appends block with fact of id
+func (c *testChain) mine(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var data interface{} = id
+	c.blocks = append(c.blocks, chaintest.Mine(c.blocks[len(c.blocks)-1], &chain.Fact{Id: id, Fact: &data}))
+}
+
+// cuts blockchain to height
+func (c *testChain) cut(height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blocks = c.blocks[:height+1]
+}
+
+// hook server receiving payloads
+type receiver struct {
+	*httptest.Server
+	payloads chan *Payload
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{payloads: make(chan *Payload, 16)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		timestamp := req.Header.Get("X-Blkchn-Timestamp")
+		sent, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+			t.Errorf("want current timestamp, got %q", timestamp)
+		}
+		if req.Header.Get("X-Blkchn-Signature") != Sign(secret, timestamp, body) {
+			t.Errorf("want payload signed with timestamp")
+		}
+
+		var p Payload
+		err = json.Unmarshal(body, &p)
+		if err != nil {
+			t.Error(err)
+		}
+		r.payloads <- &p
+	}))
+	return r
+}
+
+// returns ids of facts of the next payload
+func (r *receiver) next(t *testing.T) []string {
+	select {
+	case p := <-r.payloads:
+		var ids []string
+		for _, fact := range p.Facts {
+			ids = append(ids, fact.Id)
+		}
+		return ids
+	case <-time.After(5 * time.Second):
+		t.Fatal("want payload delivered")
+		return nil
+	}
+}
+
+// returns dispatcher of chain with hook posting to receiver
+func newDispatcher(t *testing.T, c *testChain) (*Dispatcher, *Hook, *receiver) {
+	d, err := Load("", c, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newReceiver(t, "secret")
+	h, err := d.Add(Hook{URL: r.URL, Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, h, r
+}
+
+func TestDispatch(t *testing.T) {
+	c := &testChain{blocks: chaintest.NewChain(2)}
+	d, _, r := newDispatcher(t, c)
+	defer r.Close()
+	defer d.Close()
+
+	c.mine("a")
+	d.dispatch()
+	if ids := r.next(t); len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("want fact a delivered, got %v", ids)
+	}
+}
+
+func TestRewind(t *testing.T) {
+	c := &testChain{blocks: chaintest.NewChain(2)}
+	d, h, r := newDispatcher(t, c)
+	defer r.Close()
+	defer d.Close()
+
+	c.mine("a")
+	c.mine("b")
+	d.dispatch()
+	r.next(t)
+	r.next(t)
+
+	// blocks after a are replaced by fork
+	c.cut(2)
+	c.mine("c")
+	d.dispatch()
+	if ids := r.next(t); len(ids) != 1 || ids[0] != "c" {
+		t.Fatalf("want fact c of fork delivered, got %v", ids)
+	}
+	if hook, _ := d.Hook(h.Id); hook.Next != 4 {
+		t.Fatalf("want hook at block 4, got %d", hook.Next)
+	}
+}
+
+func TestPrunedGap(t *testing.T) {
+	c := &testChain{blocks: chaintest.NewChain(2)}
+	d, h, r := newDispatcher(t, c)
+	defer r.Close()
+	defer d.Close()
+
+	c.mine("a")
+	c.mine("b")
+	c.mine("c")
+	c.first = 4
+	d.dispatch()
+	if ids := r.next(t); len(ids) != 1 || ids[0] != "c" {
+		t.Fatalf("want fact c of kept block delivered, got %v", ids)
+	}
+
+	var skipped *Delivery
+	for _, t := range d.Deliveries(h.Id) {
+		if t.Status == SKIPPED {
+			skipped = t
+		}
+	}
+	if skipped == nil || skipped.BlockIndex != 2 || skipped.Skipped != 2 {
+		t.Fatalf("want blocks 2 and 3 reported as skipped, got %+v", skipped)
+	}
+}